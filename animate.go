@@ -0,0 +1,218 @@
+package kvg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// How the strokes of an animation are drawn.
+type AnimationMethod int
+
+const (
+	// CSS animations of stroke-dashoffset, which work in browsers.
+	AnimateCSS AnimationMethod = iota
+	// SMIL animate elements, which work in some SVG viewers without
+	// CSS.
+	AnimateSMIL
+)
+
+// Options for Animate. The zero value draws the strokes one after the
+// other in the colour of the file, with no stroke numbers.
+type AnimateOptions struct {
+	Method AnimationMethod
+	// The speed of drawing, in units of the viewBox per second. If
+	// this is zero, 100 is used.
+	Speed float64
+	// The time before the first stroke, in seconds.
+	Start float64
+	// The time between the end of one stroke and the start of the
+	// next, in seconds.
+	Delay float64
+	// The colours of the strokes, such as "#c00000", used in turn. If
+	// this is empty, the stroke colour of the file is used.
+	Colors []string
+	// If this is not empty, the whole kanji is drawn underneath the
+	// animation in this colour, so the strokes still to be drawn can
+	// be seen.
+	Ghost string
+	// Show the stroke numbers, each one appearing as its stroke
+	// starts to be drawn.
+	Numbers bool
+}
+
+// The value of the property name in a style attribute such as
+// "fill:none;stroke-width:3", or the empty string.
+func styleProperty(style, name string) string {
+	for _, decl := range strings.Split(style, ";") {
+		k, v, ok := strings.Cut(decl, ":")
+		if ok && strings.TrimSpace(k) == name {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// The width of the strokes of kanjivg, from the style of its
+// StrokePaths group, or 3 as in the KanjiVG files.
+func strokeWidth(kanjivg *SVG) float64 {
+	if len(kanjivg.Groups) > 0 {
+		w, err := strconv.ParseFloat(styleProperty(kanjivg.Groups[0].Style, "stroke-width"), 64)
+		if err == nil && w > 0 {
+			return w
+		}
+	}
+	return 3
+}
+
+// The stroke number labels of kanjivg, or nil if it has none.
+func (kanjivg *SVG) labels() *Group {
+	if len(kanjivg.Groups) < 2 {
+		return nil
+	}
+	return &kanjivg.Groups[1]
+}
+
+// A writer of simple SVG elements.
+type svgWriter struct {
+	bytes.Buffer
+}
+
+// Write the start tag of the element name, with attrs in pairs of
+// name and value. If empty is true, the tag is closed.
+func (sw *svgWriter) tag(name string, empty bool, attrs ...string) {
+	sw.WriteString("<" + name)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		sw.WriteString(" " + attrs[i] + `="`)
+		xml.EscapeText(sw, []byte(attrs[i+1]))
+		sw.WriteString(`"`)
+	}
+	if empty {
+		sw.WriteString("/>")
+		return
+	}
+	sw.WriteString(">")
+}
+
+// Write the svg element of kanjivg, without its contents.
+func (sw *svgWriter) svgTag(kanjivg *SVG) {
+	attrs := []string{"xmlns", SVGNamespace, "width", kanjivg.Width, "height", kanjivg.Height}
+	if len(kanjivg.ViewBox) > 0 {
+		attrs = append(attrs, "viewBox", kanjivg.ViewBox)
+	}
+	sw.tag("svg", false, attrs...)
+	sw.WriteString("\n")
+}
+
+// Format a number of seconds or a length for CSS or SMIL.
+func animNumber(v float64) string {
+	return formatNumber(v, 3)
+}
+
+// Write an SVG to w which draws the strokes of kanjivg in order, each
+// taking a time in proportion to its length. The output is one
+// self-contained file. The error is a PathError if a stroke cannot be
+// parsed, or ErrNoBaseGroup, or is from w.
+func Animate(w io.Writer, kanjivg *SVG, opts *AnimateOptions) (err error) {
+	if opts == nil {
+		opts = &AnimateOptions{}
+	}
+	if !kanjivg.HasBaseGroup() {
+		return ErrNoBaseGroup
+	}
+	speed := opts.Speed
+	if speed <= 0 {
+		speed = 100
+	}
+	width := strokeWidth(kanjivg)
+	paths := kanjivg.GetPaths()
+	lengths := make([]float64, len(paths))
+	for i, p := range paths {
+		lengths[i], err = p.Length()
+		if err != nil {
+			return &PathError{ID: p.ID, Err: err}
+		}
+	}
+	var sw svgWriter
+	sw.svgTag(kanjivg)
+	smil := opts.Method == AnimateSMIL
+	if !smil {
+		sw.WriteString("<style>\n@keyframes kvg-draw { to { stroke-dashoffset: 0; } }\n" +
+			"@keyframes kvg-show { to { visibility: visible; } }\n</style>\n")
+	}
+	style := kanjivg.Groups[0].Style
+	if opts.Ghost != "" {
+		sw.tag("g", false, "style", style)
+		sw.WriteString("\n")
+		for _, p := range paths {
+			// The stroke attribute of the path itself, unlike that of
+			// the group, is not overridden by the style of the group.
+			sw.WriteString("\t")
+			sw.tag("path", true, "d", p.D, "stroke", opts.Ghost)
+			sw.WriteString("\n")
+		}
+		sw.WriteString("</g>\n")
+	}
+	sw.tag("g", false, "id", kanjivg.Groups[0].ID, "style", style)
+	sw.WriteString("\n")
+	// The time at which each stroke starts.
+	begins := make([]float64, len(paths))
+	t := opts.Start
+	for i, p := range paths {
+		begins[i] = t
+		dur := lengths[i] / speed
+		t += dur + opts.Delay
+		// The dash is longer than the stroke, and starts further
+		// back than its length, so the round cap does not show
+		// before the stroke starts.
+		dash := animNumber(lengths[i] + width)
+		offset := animNumber(lengths[i] + 2*width)
+		attrs := []string{"id", p.ID, "d", p.D}
+		if len(opts.Colors) > 0 {
+			attrs = append(attrs, "stroke", opts.Colors[i%len(opts.Colors)])
+		}
+		sw.WriteString("\t")
+		if smil {
+			attrs = append(attrs, "stroke-dasharray", dash+" "+dash, "stroke-dashoffset", offset)
+			sw.tag("path", false, attrs...)
+			sw.tag("animate", true, "attributeName", "stroke-dashoffset", "from", offset, "to", "0",
+				"begin", animNumber(begins[i])+"s", "dur", animNumber(dur)+"s", "fill", "freeze")
+			sw.WriteString("</path>\n")
+			continue
+		}
+		attrs = append(attrs, "style", fmt.Sprintf(
+			"stroke-dasharray:%s %s;stroke-dashoffset:%s;animation:kvg-draw %ss linear %ss forwards",
+			dash, dash, offset, animNumber(dur), animNumber(begins[i])))
+		sw.tag("path", true, attrs...)
+		sw.WriteString("\n")
+	}
+	sw.WriteString("</g>\n")
+	if labels := kanjivg.labels(); opts.Numbers && labels != nil {
+		sw.tag("g", false, "id", labels.ID, "style", labels.Style)
+		sw.WriteString("\n")
+		for i, p := range paths {
+			text := labels.Label(p)
+			if text == nil {
+				continue
+			}
+			begin := animNumber(begins[i])
+			sw.WriteString("\t")
+			if smil {
+				sw.tag("text", false, "transform", text.Transform, "visibility", "hidden")
+				sw.tag("set", true, "attributeName", "visibility", "to", "visible", "begin", begin+"s")
+			} else {
+				sw.tag("text", false, "transform", text.Transform,
+					"style", "visibility:hidden;animation:kvg-show 0s linear "+begin+"s forwards")
+			}
+			xml.EscapeText(&sw, text.Content)
+			sw.WriteString("</text>\n")
+		}
+		sw.WriteString("</g>\n")
+	}
+	sw.WriteString("</svg>\n")
+	_, err = sw.WriteTo(w)
+	return err
+}
@@ -0,0 +1,61 @@
+package kvg
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAnimate(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	length, err := svg.GetPaths()[0].Length()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := animNumber(length/50+0.5) + "s"
+	for _, method := range []AnimationMethod{AnimateCSS, AnimateSMIL} {
+		var out strings.Builder
+		err = Animate(&out, &svg, &AnimateOptions{
+			Method:  method,
+			Speed:   50,
+			Delay:   0.5,
+			Colors:  []string{"#c00000", "#00c000"},
+			Ghost:   "#e0e0e0",
+			Numbers: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Count the elements, which also checks the output is XML.
+		counts := make(map[string]int)
+		d := xml.NewDecoder(strings.NewReader(out.String()))
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Method %d: %s", method, err)
+			}
+			if el, ok := tok.(xml.StartElement); ok {
+				counts[el.Name.Local]++
+			}
+		}
+		if counts["path"] != 24 || counts["text"] != 12 || counts["g"] != 3 {
+			t.Errorf("Method %d: wrong elements %v", method, counts)
+		}
+		if method == AnimateSMIL && (counts["animate"] != 12 || counts["set"] != 12) {
+			t.Errorf("Wrong SMIL elements %v", counts)
+		}
+		if strings.Count(out.String(), `stroke="#e0e0e0"`) != 12 {
+			t.Errorf("Method %d: ghost strokes are not coloured", method)
+		}
+		if !strings.Contains(out.String(), second) || !strings.Contains(out.String(), `stroke="#00c000"`) {
+			t.Errorf("Method %d: second stroke does not start at %s:\n%s", method, second, out.String())
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package kvg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// This is increased each time the structures stored in the cache
+// change, so that old cache files are ignored rather than giving
+// incomplete trees.
+const cacheVersion = 4
+
+// A parsed file in the cache, with the hash of the contents it was
+// parsed from.
+type cacheEntry struct {
+	Hash [sha256.Size]byte
+	SVG  SVG
+}
+
+// What is written to the cache file.
+type cacheFile struct {
+	Version int
+	Entries map[string]*cacheEntry
+}
+
+// An on-disk cache of parsed KanjiVG files. Each file is stored under
+// its name within its corpus, with a hash of its contents, so the file
+// is only parsed again if its contents change. Use one cache file for
+// each corpus. To use a cache with Walk or Index, put it in the
+// WalkOptions.
+type Cache struct {
+	file    string
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	dirty   bool
+	// The number of files found in the cache, and the number parsed.
+	hits, misses int
+}
+
+// Open the cache stored in file. If file does not exist, or is not a
+// cache file of the current version, the cache starts empty, and file
+// is written when Save is called. The error value is from reading
+// file.
+func OpenCache(file string) (c *Cache, err error) {
+	c = &Cache{
+		file:    file,
+		entries: make(map[string]*cacheEntry),
+	}
+	contents, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cf cacheFile
+	err = gob.NewDecoder(bytes.NewReader(contents)).Decode(&cf)
+	if err != nil || cf.Version != cacheVersion || cf.Entries == nil {
+		// A broken or old cache is no use, so start again.
+		c.dirty = true
+		return c, nil
+	}
+	c.entries = cf.Entries
+	return c, nil
+}
+
+// Write the cache back out to its file, if anything has changed. The
+// cache is written to a temporary file which is then renamed, so
+// programs running at the same time never see a partial cache.
+func (c *Cache) Save() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	var buf bytes.Buffer
+	err = gob.NewEncoder(&buf).Encode(cacheFile{
+		Version: cacheVersion,
+		Entries: c.entries,
+	})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.file), filepath.Base(c.file)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if err == nil {
+		err = tmp.Close()
+	} else {
+		tmp.Close()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// Parse contents, the contents of the file called name, using the
+// cached parse if contents have not changed since it was stored. The
+// returned SVG is a copy which the caller may alter. This is safe to
+// call from several goroutines at once.
+func (c *Cache) Parse(name string, contents []byte) (svg SVG, err error) {
+	hash := sha256.Sum256(contents)
+	c.mu.Lock()
+	e := c.entries[name]
+	if e != nil && e.Hash == hash {
+		c.hits++
+		c.mu.Unlock()
+		return e.SVG.clone(true), nil
+	}
+	c.misses++
+	c.mu.Unlock()
+	svg, err = ParseKanji(contents)
+	if err != nil {
+		return svg, err
+	}
+	e = &cacheEntry{Hash: hash, SVG: svg.clone(false)}
+	c.mu.Lock()
+	c.entries[name] = e
+	c.dirty = true
+	c.mu.Unlock()
+	return svg, nil
+}
+
+// Make a copy of svg which shares no memory with it. If link is true,
+// the parent pointers are linked up within the copy, otherwise they
+// are left empty, since the gob encoder cannot deal with them.
+func (svg *SVG) clone(link bool) (c SVG) {
+	c = *svg
+	c.Extra = cloneAttrs(svg.Extra)
+	c.Format = svg.Format.clone()
+	c.Groups = make([]Group, len(svg.Groups))
+	for i := range svg.Groups {
+		c.Groups[i] = svg.Groups[i].clone()
+		if link {
+			c.Groups[i].linkParents()
+		}
+	}
+	return c
+}
+
+// Make a deep copy of g, without its parent pointers.
+func (g *Group) clone() (c Group) {
+	c = *g
+	c.Parent = nil
+	c.Extra = cloneAttrs(g.Extra)
+	c.Format = g.Format.clone()
+	c.Children = make([]Child, len(g.Children))
+	for i := range g.Children {
+		o := &g.Children[i]
+		n := &c.Children[i]
+		*n = *o
+		n.Parent = nil
+		n.Group = o.Group.clone()
+		n.Path.Parent = nil
+		n.Path.Extra = cloneAttrs(o.Path.Extra)
+		n.Path.Format = o.Path.Format.clone()
+		n.Text.Parent = nil
+		n.Text.Content = append([]byte(nil), o.Text.Content...)
+		n.Text.Extra = cloneAttrs(o.Text.Extra)
+		n.Text.Format = o.Text.Format.clone()
+	}
+	return c
+}
+
+// Make a copy of f which shares no memory with it.
+func (f Format) clone() Format {
+	f.Attrs = cloneAttrs(f.Attrs)
+	f.Space = append([]string(nil), f.Space...)
+	return f
+}
+
+func cloneAttrs(attrs []xml.Attr) []xml.Attr {
+	return append([]xml.Attr(nil), attrs...)
+}
+
+// Set the parent pointers of the children of g, and their children,
+// to point to the right places within g.
+func (g *Group) linkParents() {
+	for i := range g.Children {
+		c := &g.Children[i]
+		c.Parent = g
+		switch {
+		case c.IsGroup:
+			c.Group.Parent = c
+			c.Group.linkParents()
+		case c.IsText:
+			c.Text.Parent = c
+		case c.IsOther:
+			// Other children have nothing to link.
+		default:
+			c.Path.Parent = c
+		}
+	}
+}
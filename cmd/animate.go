@@ -0,0 +1,50 @@
+/*
+   Write an SVG which draws the strokes of a KanjiVG file in order, to
+   standard output.
+
+   For example,
+
+       animate -numbers -colors '#c00000,#000000' 08475.svg > 葵.svg
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"kvg"
+	"os"
+	"strings"
+)
+
+func main() {
+	smilFlag := flag.Bool("smil", false, "Use SMIL rather than CSS")
+	numbersFlag := flag.Bool("numbers", false, "Show the stroke numbers")
+	speedFlag := flag.Float64("speed", 100, "Speed of drawing in units per second")
+	delayFlag := flag.Float64("delay", 0.2, "Seconds between strokes")
+	colorsFlag := flag.String("colors", "", "Colours of the strokes, separated by commas")
+	ghostFlag := flag.String("ghost", "", "Colour of the strokes before they are drawn")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: animate [options] file.svg\n")
+		os.Exit(1)
+	}
+	svg := kvg.ReadKanjiFileOrDie(flag.Arg(0))
+	opts := kvg.AnimateOptions{
+		Speed:   *speedFlag,
+		Delay:   *delayFlag,
+		Ghost:   *ghostFlag,
+		Numbers: *numbersFlag,
+	}
+	if *smilFlag {
+		opts.Method = kvg.AnimateSMIL
+	}
+	if len(*colorsFlag) > 0 {
+		opts.Colors = strings.Split(*colorsFlag, ",")
+	}
+	err := kvg.Animate(os.Stdout, &svg, &opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"kvg"
+	"os"
 )
 
 func main() {
-	kvg.ExamineAllFilesSimple(bogusGroup)
+	err := kvg.WalkDir(context.Background(), kvg.KVDir,
+		&kvg.WalkOptions{Ordered: true}, bogusGroup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 }
-func bogusGroup(file string) {
-	_, base := kvg.Grab(file)
+
+func bogusGroup(kf *kvg.KanjiFile) error {
+	file := kf.Path
+	base := kf.SVG.BaseGroup()
 	groups := base.GetGroups()
 	for _, group := range groups {
 		if len(group.Element) > 0 {
@@ -34,4 +43,5 @@ func bogusGroup(file string) {
 		fmt.Printf("%s: %s has one child and no element or position\n",
 			file, group.ID)
 	}
+	return nil
 }
@@ -0,0 +1,84 @@
+/*
+   Convert between the KanjiVG files and the combined kanjivg.xml
+   file.
+
+   With no flags, the files in the directory or zip archive given by
+   -dir are written to standard output as a combined file. With -split, the
+   combined file given as the argument, or standard input if there is
+   none, is written out as separate files into the directory given by
+   -dir.
+
+   The -numbers flag keeps the stroke numbers in the combined file, so
+   that the files made from it again are the same as the originals.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"kvg"
+	"os"
+)
+
+func split(dir string, r io.Reader) (err error) {
+	corpus := kvg.NewCorpus(dir)
+	cr := kvg.NewCombinedReader(bufio.NewReader(r))
+	for {
+		k, err := cr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = kvg.WriteKanjiFile(corpus.Path(k.Name()), &k.SVG)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func combine(dir string, numbers bool) (err error) {
+	corpus, err := kvg.OpenCorpus(dir)
+	if err != nil {
+		return err
+	}
+	defer corpus.Close()
+	out := bufio.NewWriter(os.Stdout)
+	opts := kvg.CombinedOptions{StrokeNumbers: numbers}
+	err = corpus.WriteCombined(context.Background(), out, &opts)
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+func main() {
+	dirFlag := flag.String("dir", kvg.KVDir, "Directory or zip archive of KanjiVG files")
+	splitFlag := flag.Bool("split", false, "Split a combined file into the directory")
+	numbersFlag := flag.Bool("numbers", false, "Keep the stroke numbers in the combined file")
+	flag.Parse()
+	var err error
+	if *splitFlag {
+		in := os.Stdin
+		if flag.NArg() > 0 {
+			in, err = os.Open(flag.Arg(0))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			defer in.Close()
+		}
+		err = split(*dirFlag, in)
+	} else {
+		err = combine(*dirFlag, *numbersFlag)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
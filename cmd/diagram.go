@@ -0,0 +1,73 @@
+/*
+   Write a stroke order diagram of a KanjiVG file, with one frame for
+   each stroke, to standard output as an SVG, a PNG or an animated GIF.
+
+   For example,
+
+       diagram -format png -columns 6 -ghost '#dddddd' 08475.svg > 葵.png
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"kvg"
+	"os"
+)
+
+// Parse the colour s, or stop the program. The empty string gives nil.
+func parseColorOrDie(s string) color.Color {
+	if len(s) == 0 {
+		return nil
+	}
+	c, err := kvg.ParseColor(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	return c
+}
+
+func main() {
+	formatFlag := flag.String("format", "svg", "Output format: svg, png or gif")
+	columnsFlag := flag.Int("columns", 0, "Frames in each row, or 0 for one row")
+	sizeFlag := flag.Int("size", 0, "Width and height of each frame in pixels")
+	colorFlag := flag.String("color", "", "Colour of the strokes")
+	highlightFlag := flag.String("highlight", "", "Colour of the newest stroke")
+	ghostFlag := flag.String("ghost", "", "Colour of the strokes still to be drawn")
+	backgroundFlag := flag.String("background", "", "Colour of the background")
+	delayFlag := flag.Float64("delay", 0.5, "Seconds for each frame of a GIF")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: diagram [options] file.svg\n")
+		os.Exit(1)
+	}
+	formats := map[string]kvg.DiagramFormat{
+		"svg": kvg.DiagramSVG,
+		"png": kvg.DiagramPNG,
+		"gif": kvg.DiagramGIF,
+	}
+	format, ok := formats[*formatFlag]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format %s\n", *formatFlag)
+		os.Exit(1)
+	}
+	svg := kvg.ReadKanjiFileOrDie(flag.Arg(0))
+	opts := kvg.DiagramOptions{
+		Format:     format,
+		Columns:    *columnsFlag,
+		Size:       *sizeFlag,
+		Color:      parseColorOrDie(*colorFlag),
+		Highlight:  parseColorOrDie(*highlightFlag),
+		Ghost:      parseColorOrDie(*ghostFlag),
+		Background: parseColorOrDie(*backgroundFlag),
+		Delay:      *delayFlag,
+	}
+	err := kvg.Diagram(os.Stdout, &svg, &opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,21 +1,41 @@
-// Search all the files for paths which are empty.
+/*
+   Search all the files for paths which are empty.
+
+   The files with fewer stroke numbers than paths, where the paths
+   with no number are empty, are printed. With --fix, the empty paths
+   with no number are removed, and the paths are renumbered. With
+   --dry-run, the removals are shown as a unified diff instead, and
+   with --backup, the old files are kept with "~" added to their names.
+*/
 
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"kvg"
+	"os"
 )
 
-func emptyPath(file string) {
-	svg, base := kvg.Grab(file)
+var fix = false
+var fixer *kvg.Fixer
+
+func emptyPath(kf *kvg.KanjiFile) error {
+	file := kf.Path
+	svg := &kf.SVG
+	if len(svg.Groups) < 2 {
+		fmt.Printf("%s: no stroke numbers.\n", kvg.TFile(file))
+		return nil
+	}
+	base := svg.BaseGroup()
 	paths := base.GetPaths()
 	nums := &svg.Groups[1]
 	nc := len(nums.Children)
 	if nc == len(paths) {
 		// This file is OK, the number of paths is the same as the number
 		// of stroke labels.
-		return
+		return nil
 	}
 	if nc < len(paths) {
 		fmt.Printf("%s: missing %d numbers.\n", kvg.TFile(file), len(paths)-nc)
@@ -24,32 +44,74 @@ func emptyPath(file string) {
 		// This does not happen for any file.
 		fmt.Printf("%s: too many stroke numbers %d > %d.\n",
 			kvg.TFile(file), nc, len(paths))
-		return
+		return nil
 	}
-	emptyPaths := make([]bool, len(paths))
 	found := false
-	for i, p := range paths {
-		if i < nc {
-			continue
-		}
+	for _, p := range paths[nc:] {
 		if len(p.D) == 0 {
-			emptyPaths[i] = true
 			found = true
 		}
 	}
 	if !found {
-		return
+		return nil
+	}
+	fmt.Printf("%s\n", kvg.TFile(file))
+	if !fix {
+		return nil
 	}
-	newchild := make([]kvg.Child, 0)
-	for i := range nums.Children {
-		if !emptyPaths[i] {
-			newchild = append(newchild, nums.Children[i])
+	_, err := fixer.Fix(file, kf.Contents, svg, []kvg.Edit{{
+		Description: "remove the empty paths with no stroke number",
+		Apply: func(svg *kvg.SVG) error {
+			return removeEmpty(svg, nc)
+		},
+	}})
+	return err
+}
+
+// Remove the empty paths of svg after the first nc paths, and
+// renumber the ids of what is left. This goes backwards, so that
+// removing a path does not move the ones still to be removed.
+func removeEmpty(svg *kvg.SVG, nc int) error {
+	paths := svg.BaseGroup().GetPaths()
+	for i := len(paths) - 1; i >= nc; i-- {
+		p := paths[i]
+		if len(p.D) > 0 {
+			continue
+		}
+		if p.Parent == nil || p.Parent.Parent == nil {
+			return fmt.Errorf("path %s has no parent", p.ID)
+		}
+		g := p.Parent.Parent
+		for j := range g.Children {
+			if &g.Children[j] == p.Parent {
+				g.RemoveChild(j)
+				break
+			}
 		}
 	}
-	nums.Children = newchild
-	fmt.Printf("%s\n", kvg.TFile(file))
+	svg.RenumberXML()
+	return nil
 }
 
 func main() {
-	kvg.ExamineAllFilesSimple(emptyPath)
+	dirFlag := flag.String("dir", kvg.KVDir, "Directory of KanjiVG files")
+	fixFlag := flag.Bool("fix", false, "Remove the empty paths")
+	dryRunFlag := flag.Bool("dry-run", false, "Show the removals as a diff without writing them")
+	backupFlag := flag.Bool("backup", false, "Keep the files as they were before fixing, as file~")
+	flag.Parse()
+	fix = *fixFlag || *dryRunFlag
+	fixOpts := kvg.FixOptions{
+		DryRun: *dryRunFlag,
+		Backup: *backupFlag,
+	}
+	if *dryRunFlag {
+		fixOpts.Diff = os.Stdout
+	}
+	fixer = kvg.NewFixer(&fixOpts)
+	err := kvg.WalkDir(context.Background(), *dirFlag,
+		&kvg.WalkOptions{Ordered: true}, emptyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,57 @@
+/*
+   Write the strokes of the KanjiVG files as lists of points, one
+   stroke per line of JSON, or in the binary format of
+   kvg.StrokeWriter with -binary.
+
+   Give kanji on the command line to export only those kanji and their
+   variants, otherwise all of the files are exported.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"kvg"
+	"os"
+	"strings"
+)
+
+func main() {
+	dirFlag := flag.String("dir", kvg.KVDir, "Directory or zip archive of KanjiVG files")
+	pointsFlag := flag.Int("points", 0, "Number of points for each stroke (default flatten to -tolerance)")
+	toleranceFlag := flag.Float64("tolerance", 0.1, "Largest distance of the points from the stroke")
+	binaryFlag := flag.Bool("binary", false, "Write the binary format")
+	flag.Parse()
+	corpus, err := kvg.OpenCorpus(*dirFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	defer corpus.Close()
+	opts := kvg.ExportOptions{
+		Points:    *pointsFlag,
+		Tolerance: *toleranceFlag,
+	}
+	if *binaryFlag {
+		opts.Format = kvg.ExportBinary
+	}
+	kanji := strings.Join(flag.Args(), "")
+	if len(kanji) > 0 {
+		opts.Filter = func(kf *kvg.KanjiFile) bool {
+			_, num, _ := kvg.FileToParts(kf.Name)
+			return strings.ContainsRune(kanji, rune(num))
+		}
+	}
+	out := bufio.NewWriter(os.Stdout)
+	err = corpus.ExportStrokes(context.Background(), out, &opts)
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
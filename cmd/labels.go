@@ -0,0 +1,38 @@
+/*
+   Move the stroke number labels of the files given on the command line
+   to places worked out from the strokes, and write the files back.
+
+   With -new, the StrokeNumbers group is made again from scratch, with
+   one label for each stroke, for example after strokes have been added,
+   deleted or reordered.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"kvg"
+	"os"
+)
+
+func main() {
+	newFlag := flag.Bool("new", false, "Make the labels again from scratch")
+	flag.Parse()
+	for _, file := range flag.Args() {
+		svg := kvg.ReadKanjiFileOrDie(file)
+		var err error
+		if *newFlag {
+			err = svg.MakeLabels(nil)
+		} else {
+			err = svg.PlaceLabels(nil)
+		}
+		if err == nil {
+			err = kvg.WriteKanjiFile(file, &svg)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			os.Exit(1)
+		}
+	}
+}
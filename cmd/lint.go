@@ -0,0 +1,185 @@
+/*
+   Check KanjiVG files for problems, using the rules of the lint
+   package.
+
+   With no file names, all the files of the corpus in the directory or
+   zip file given by -dir are checked. Use -list to see the rules, and
+   -rules to choose some of them.
+
+   The issues are written to standard output in the format given by
+   -format: "text", "jsonl" for JSON Lines, "sarif" for SARIF 2.1.0,
+   or "compact" for lines starting with file:line:col. The exit status
+   is 0 if no issues were found, or only info, 1 if the worst was a
+   warning, 2 if there was an error, and 3 if the check could not be
+   run.
+
+   With -fix, the fixes of the issues which have them are applied and
+   the files are written. With -dry-run, the fixes are shown as a
+   unified diff on standard error instead, and with -backup, the old
+   files are kept with "~" added to their names. The issues written
+   are those found before fixing.
+
+   Issues which are not problems are suppressed by the file given by
+   -suppressions, a JSON array of objects with a "file" name or
+   pattern, an "id", or an "element", and optionally a "rule", and a
+   "reason". By default, suppressions.json is used if it exists.
+
+   With -write-baseline, the issues found are written to a baseline
+   file, and with -baseline, the issues in a baseline file are not
+   reported, so that only new issues are.
+
+   For example,
+
+       lint -rules bogus-group,empty-path
+       lint -rules format -format compact 08475.svg
+       lint -rules base-position -dry-run
+       lint -write-baseline baseline.json
+       lint -baseline baseline.json
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"kvg"
+	"kvg/lint"
+	"os"
+	"strings"
+)
+
+// The suppressions used if -suppressions is not given, if the file
+// exists.
+const defaultSuppressions = "suppressions.json"
+
+func main() {
+	dirFlag := flag.String("dir", kvg.KVDir, "Directory or zip file of KanjiVG files")
+	rulesFlag := flag.String("rules", "", "Rules to run, separated by commas (default all)")
+	listFlag := flag.Bool("list", false, "List the rules")
+	formatFlag := flag.String("format", "text", "Output format: text, jsonl, sarif or compact")
+	fixFlag := flag.Bool("fix", false, "Apply the fixes of the issues")
+	dryRunFlag := flag.Bool("dry-run", false, "Show the fixes as a diff without writing them")
+	backupFlag := flag.Bool("backup", false, "Keep the files as they were before fixing, as file~")
+	suppressFlag := flag.String("suppressions", defaultSuppressions, "File of suppressions")
+	baselineFlag := flag.String("baseline", "", "Do not report the issues in this baseline file")
+	writeBaselineFlag := flag.String("write-baseline", "", "Write the issues found to this baseline file")
+	flag.Parse()
+	if *listFlag {
+		for _, name := range lint.Names() {
+			r, _ := lint.NewRule(name)
+			fmt.Printf("%-16s %s\n", name, r.Doc())
+		}
+		return
+	}
+	var names []string
+	if len(*rulesFlag) > 0 {
+		names = strings.Split(*rulesFlag, ",")
+	}
+	linter, err := lint.New(names...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(3)
+	}
+	linter.Suppressions, err = lint.ReadSuppressions(*suppressFlag)
+	if err != nil {
+		if *suppressFlag != defaultSuppressions || !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(3)
+		}
+	}
+	if len(*baselineFlag) > 0 && len(*writeBaselineFlag) > 0 {
+		fmt.Fprintf(os.Stderr, "Use only one of -baseline and -write-baseline\n")
+		os.Exit(3)
+	}
+	if len(*baselineFlag) > 0 {
+		linter.Baseline, err = lint.ReadBaseline(*baselineFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(3)
+		}
+	}
+	baseline := lint.NewBaseline()
+	format, err := lint.ParseOutputFormat(*formatFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(3)
+	}
+	w := lint.NewWriter(os.Stdout, format)
+	// The issues with fixes, by file, and the files in order.
+	fixes := make(map[string][]lint.Issue)
+	var files []string
+	report := func(i *lint.Issue) {
+		err := w.Write(i)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(3)
+		}
+		baseline.Add(i)
+		if i.Fix != nil {
+			if fixes[i.File] == nil {
+				files = append(files, i.File)
+			}
+			fixes[i.File] = append(fixes[i.File], *i)
+		}
+	}
+	if flag.NArg() > 0 {
+		for _, file := range flag.Args() {
+			for _, i := range linter.CheckPath(file) {
+				report(&i)
+			}
+		}
+	} else {
+		corpus, err := kvg.OpenCorpus(*dirFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(3)
+		}
+		if (*fixFlag || *dryRunFlag) && len(corpus.Dir()) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: cannot fix the files of a corpus which is not a directory\n", *dirFlag)
+			corpus.Close()
+			os.Exit(3)
+		}
+		err = linter.CheckCorpus(context.Background(), corpus, report)
+		corpus.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(3)
+		}
+	}
+	err = w.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(3)
+	}
+	if len(*writeBaselineFlag) > 0 {
+		err = baseline.WriteFile(*writeBaselineFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(3)
+		}
+	}
+	if linter.Hidden > 0 {
+		fmt.Fprintf(os.Stderr, "%d known issues not reported\n", linter.Hidden)
+	}
+	if *fixFlag || *dryRunFlag {
+		fixOpts := kvg.FixOptions{
+			DryRun: *dryRunFlag,
+			Backup: *backupFlag,
+		}
+		if *dryRunFlag {
+			fixOpts.Diff = os.Stderr
+		}
+		fixer := kvg.NewFixer(&fixOpts)
+		for _, file := range files {
+			_, err = fixer.Fix(file, nil, nil, lint.Edits(fixes[file]))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(3)
+			}
+		}
+	}
+	os.Exit(w.ExitStatus())
+}
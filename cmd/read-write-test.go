@@ -7,7 +7,9 @@
    on the characters.
 
    If the flag --fix is supplied to the application, it overwrites the
-   files with corrected versions.
+   files with corrected versions. With --dry-run, it shows the
+   corrections as a unified diff instead, and with --backup, it keeps
+   the old files with "~" added to their names.
 
    If the --verbose flag is supplied, a progress message is printed.
 
@@ -20,14 +22,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"io/fs"
-	"io/ioutil"
 	"kvg"
 	"os"
-	"path/filepath"
-	"strings"
 )
 
 // True if c is whitespace.
@@ -78,14 +77,6 @@ func compareXML(file string, xmlout, xmlin []byte) {
 				end = n
 			}
 			fmt.Printf("IN:  *%s*\nOUT: *%s*\n", xmlin[start:end], xmlout[start:end])
-			if fix {
-				// Write the file back out
-				err := ioutil.WriteFile(file, xmlout, 0644)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", file, err)
-					os.Exit(1)
-				}
-			}
 			break
 		}
 		offset++
@@ -164,98 +155,137 @@ func checkRadical(file string, svg *kvg.SVG, base *kvg.Group, kanji rune) {
 }
 
 // Check the format of the specified file.
-func readWriteTest(file string) {
-	contents, oerr := ioutil.ReadFile(file)
-	if oerr != nil {
-		fmt.Fprintf(os.Stderr, "Error opening %s: %s\n", file, oerr)
-		os.Exit(1)
-	}
-	svg, err := kvg.ParseKanji(contents)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Too bad %s\n", err)
-		os.Exit(1)
-	}
+func readWriteTest(kf *kvg.KanjiFile) error {
+	file := kf.Path
+	contents := kf.Contents
+	svg := kf.SVG
 	id, kanji, _ := kvg.FileToParts(file)
 	_, base := svg.Base()
 	baseGroup := svg.BaseGroup()
 	baseElement := baseGroup.Element
+	// The corrections, which are made after checking.
+	var edits []kvg.Edit
 	rebased := false
+	rebase := func() {
+		if fix && !rebased {
+			edits = append(edits, kvg.Edit{
+				Description: "set the base to kvg:" + id,
+				Apply: func(svg *kvg.SVG) error {
+					return svg.SetBase("kvg:" + id)
+				},
+			})
+			rebased = true
+		}
+	}
 	if len(baseElement) > 0 {
 		baseKanji := []rune(baseElement)[0]
 		if int64(baseKanji) != kanji {
-			abbrevname := strings.Replace(file, kvg.KVDir+"/", "", 1)
 			fmt.Printf("File name, %c, [%s] disagrees with element %s [%05x]\n",
-				rune(kanji), abbrevname, baseElement, int64(baseKanji))
+				rune(kanji), kf.Name, baseElement, int64(baseKanji))
 			if fix {
-				baseGroup.Element = string([]rune{rune(kanji)})
+				edits = append(edits, kvg.Edit{
+					Description: "set the element",
+					Apply: func(svg *kvg.SVG) error {
+						svg.BaseGroup().Element = string([]rune{rune(kanji)})
+						return nil
+					},
+				})
 			}
 		}
 	}
 	if svg.Groups[0].ID != "kvg:StrokePaths_"+id {
 		fmt.Printf("StrokePaths id %s != %s\n", svg.Groups[0].ID, id)
 		totalFails++
-		if fix && !rebased {
-			svg.SetBase("kvg:" + id)
-			rebased = true
-		}
+		rebase()
 	}
 	if len(svg.Groups) > 1 && svg.Groups[1].ID != "kvg:StrokeNumbers_"+id {
 		fmt.Printf("StrokeNumbers id %s != %s\n", svg.Groups[1].ID, id)
 		totalFails++
-		if fix && !rebased {
-			svg.SetBase("kvg:" + id)
-			rebased = true
-		}
+		rebase()
 	}
 	if id != base {
 		fmt.Printf("Error: base name '%s' and file ID '%s' differ.\n",
 			base, id)
 		totalFails++
-		if fix && !rebased {
-			svg.SetBase("kvg:" + id)
-			rebased = true
-		}
+		rebase()
 	}
 	checkRadical(file, &svg, baseGroup, rune(kanji))
 	if len(baseGroup.Position) != 0 {
 		fmt.Printf("%s: base group has silly position %s\n",
 			file, baseGroup.Position)
 		if fix {
-			baseGroup.Position = ""
+			edits = append(edits, kvg.Edit{
+				Description: "remove the position",
+				Apply: func(svg *kvg.SVG) error {
+					svg.BaseGroup().Position = ""
+					return nil
+				},
+			})
 		}
 		totalFails++
 	}
-	svg.RenumberXML()
-	xmlout := svg.MakeXML()
+	// Write the file in the standard format rather than as it was
+	// read.
+	svg.ClearFormat()
+	xmlout, err := svg.MakeXML()
+	if err != nil {
+		totalFails++
+		return err
+	}
 	compareXML(file, xmlout, contents)
+	if fix {
+		// Write the corrections, and the file in the standard format.
+		_, err = fixer.Fix(file, contents, &svg, edits)
+		if err != nil {
+			totalFails++
+			return err
+		}
+	}
+	n++
+	fmt.Printf("%d files checked\r", n)
+	return nil
 }
 
 var fix = false
+var fixer *kvg.Fixer
 var verbose = false
 var totalFails = 0
 var whiteFails = 0
+var n = 0
 
 func main() {
 	kanjiRad = make(map[rune]map[string]string, 0)
 	fixFlag := flag.Bool("fix", false, "Fix the errors found")
+	dryRunFlag := flag.Bool("dry-run", false, "Show the fixes as a diff without writing them")
+	backupFlag := flag.Bool("backup", false, "Keep the files as they were before fixing, as file~")
 	verboseFlag := flag.Bool("verbose", false, "Print progress")
+	dirFlag := flag.String("dir", kvg.KVDir, "Directory of KanjiVG files")
+	workersFlag := flag.Int("workers", 0, "Number of files to read at once (default all CPUs)")
 	flag.Parse()
-	fix = *fixFlag
+	fix = *fixFlag || *dryRunFlag
+	fixOpts := kvg.FixOptions{
+		DryRun:      *dryRunFlag,
+		Backup:      *backupFlag,
+		ClearFormat: true,
+	}
+	if *dryRunFlag {
+		fixOpts.Diff = os.Stdout
+	}
+	fixer = kvg.NewFixer(&fixOpts)
 	verbose = *verboseFlag
-	n := 0
-	filepath.WalkDir(kvg.KVDir, func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() {
-			return nil
-		}
-		if kvg.Backup.MatchString(path) {
-			return nil
-		}
-		readWriteTest(path)
-		n++
-		fmt.Printf("%d files checked\r", n)
-		return nil
-	})
+	opts := kvg.WalkOptions{
+		Workers: *workersFlag,
+		Ordered: true,
+	}
+	corpus := kvg.NewCorpus(*dirFlag)
+	err := corpus.Walk(context.Background(), &opts, readWriteTest)
 	fmt.Println()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
 	fmt.Printf("Total failures %d\n", totalFails)
 	fmt.Printf("Whitespace-only inconsistencies %d\n", whiteFails)
+	if fix {
+		fmt.Printf("Files fixed %d\n", fixer.Changed)
+	}
 }
@@ -0,0 +1,61 @@
+/*
+   Draw a KanjiVG file as a PNG image.
+
+   For example,
+
+       render -size 400 -numbers -colors '#c00000,#000000' 08475.svg > 葵.png
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"kvg"
+	"os"
+	"strings"
+)
+
+// Parse the colour s, or stop the program.
+func parseColorOrDie(s string) color.Color {
+	c, err := kvg.ParseColor(s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	return c
+}
+
+func main() {
+	sizeFlag := flag.Int("size", 0, "Width and height of the image in pixels")
+	numbersFlag := flag.Bool("numbers", false, "Show the stroke numbers")
+	colorsFlag := flag.String("colors", "", "Colours of the strokes, separated by commas")
+	backgroundFlag := flag.String("background", "", "Colour of the background")
+	widthFlag := flag.Float64("width", 0, "Width of the strokes")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: render [options] file.svg > file.png\n")
+		os.Exit(1)
+	}
+	svg := kvg.ReadKanjiFileOrDie(flag.Arg(0))
+	opts := kvg.RenderOptions{
+		Width:       *sizeFlag,
+		Height:      *sizeFlag,
+		StrokeWidth: *widthFlag,
+		Numbers:     *numbersFlag,
+	}
+	if len(*backgroundFlag) > 0 {
+		opts.Background = parseColorOrDie(*backgroundFlag)
+	}
+	if len(*colorsFlag) > 0 {
+		for _, c := range strings.Split(*colorsFlag, ",") {
+			opts.Colors = append(opts.Colors, parseColorOrDie(c))
+		}
+	}
+	err := kvg.RenderPNG(os.Stdout, &svg, &opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"kvg"
 	"os"
 )
@@ -12,6 +13,10 @@ func main() {
 		if i == 0 {
 			continue
 		}
-		kvg.RenumberFile(file)
+		err := kvg.RenumberFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error renumbering: %s\n", err)
+			os.Exit(1)
+		}
 	}
 }
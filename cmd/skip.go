@@ -1,9 +1,13 @@
 /* Compare SKIP codes from the data to ones calculated from the
-   KanjiVG data. */
+   KanjiVG data.
+
+   The parts which need a different guess from their position, usually
+   because they have none, are in skip-exceptions.json. */
 
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -69,6 +73,38 @@ var PrintCounts = false
 
 const unknown = -1
 
+// A part of a kanji for which the SKIP code is not guessed from its
+// position.
+type skipException struct {
+	Element string `json:"element"`
+	Shape   int    `json:"shape"`
+	// The number of strokes in the first part of the SKIP code, or
+	// zero for the strokes of the part.
+	First  int    `json:"first"`
+	Reason string `json:"reason"`
+}
+
+// The exceptions, by element.
+var exceptions map[string]skipException
+
+// Read the exceptions from file.
+func readExceptions(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var list []skipException
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	exceptions = make(map[string]skipException)
+	for _, e := range list {
+		exceptions[e.Element] = e
+	}
+	return nil
+}
+
 func guessShape(base *kvg.Group) (first, second, third int) {
 	kanji := base.Element
 	if len(base.Children) == 1 {
@@ -133,21 +169,13 @@ func guessShape(base *kvg.Group) (first, second, third int) {
 	} else {
 		/* These currently lack a position field for at least some
 		   cases in KanjiVG. */
-		if element == "尺" || element == "几" || element == "广" ||
-			element == "弋" || element == "戈" ||
-			element == "耂" {
-			return 3, nchild0, nremaining
-		}
-		// Apel's unusual division into top and bottom of 衣.
-		if element == "衣" {
-			nremaining = nbase - 2
-			return 2, 2, nremaining
-		}
-		if element == "弍" {
-			nremaining = nbase - 3
-			return 3, 3, nremaining
+		if e, ok := exceptions[element]; ok {
+			if e.First > 0 {
+				nchild0 = e.First
+				nremaining = nbase - e.First
+			}
+			return e.Shape, nchild0, nremaining
 		}
-
 		if element == "一" || element == "二" {
 			return 4, nbase, 1
 		}
@@ -167,19 +195,20 @@ var unusual = 0
 var mayBeUsual = 0
 var mismatch = 0
 
-func makeSkip(file string) {
+func makeSkip(kf *kvg.KanjiFile) error {
+	file := kf.Path
 	_, kanji, _ := kvg.FileToParts(file)
 	k := rune(kanji)
 	ks := fmt.Sprintf("%c", k)
 	skip, ok := skipdic[ks]
 	if !ok {
-		return
+		return nil
 	}
 	if !unicode.In(k, unicode.Han) {
-		return
+		return nil
 	}
 	sc := skipToNums(skip)
-	_, base := kvg.Grab(file)
+	base := kf.SVG.BaseGroup()
 	bshape, a, b := guessShape(base)
 	isUnusual := false
 	if bshape != sc.shape {
@@ -260,6 +289,7 @@ func makeSkip(file string) {
 		okb++
 	}
 	total++
+	return nil
 }
 
 func main() {
@@ -273,7 +303,17 @@ func main() {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
-	kvg.ExamineAllFilesSimple(makeSkip)
+	err = readExceptions("skip-exceptions.json")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	err = kvg.WalkDir(context.Background(), kvg.KVDir,
+		&kvg.WalkOptions{Ordered: true}, makeSkip)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
 	fmt.Printf("ok %d guess wrong %d total %d  [should = %d]\n",
 		okskip, guesswrong, total, okskip+guesswrong)
 	fmt.Printf("No group = %d no position = %d, one child = %d [total = %d]\n",
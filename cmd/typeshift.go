@@ -13,8 +13,10 @@ import (
 var verbose = true
 
 func main() {
+	dirFlag := flag.String("dir", kvg.KVDir, "Directory of KanjiVG files")
 	fileFlag := flag.String("file", "", "File to read")
 	writeFlag := flag.Bool("write", false, "Perform a write operation")
+	backupFlag := flag.Bool("backup", false, "Keep the file as it was before writing, as file~")
 	shiftFlag := flag.String("shift", "", "Shifts to perform")
 	swapFlag := flag.String("swap", "", "A swap to perform")
 	verboseFlag := flag.Bool("verbose", false, "Switch on debugging")
@@ -24,8 +26,18 @@ func main() {
 		fmt.Printf("Specify the file with --file <file>\n")
 		return
 	}
-	file := kvg.KVDir + "/" + *fileFlag
-	svg := kvg.ReadKanjiFileOrDie(file)
+	corpus := kvg.NewCorpus(*dirFlag)
+	file := corpus.Path(*fileFlag)
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	svg, err := kvg.ParseKanji(contents)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+		os.Exit(1)
+	}
 	paths := svg.GetPaths()
 	n := len(paths)
 	save := make([]string, n)
@@ -63,13 +75,29 @@ func main() {
 			}
 		}
 	}
-	if *writeFlag {
-		for i := range paths {
-			if shifts[i] != i {
-				paths[i].Type = save[shifts[i]]
+	// Without --write, show the change to the file as a diff.
+	fixOpts := kvg.FixOptions{
+		DryRun: !*writeFlag,
+		Backup: *backupFlag,
+	}
+	if !*writeFlag {
+		fixOpts.Diff = os.Stdout
+	}
+	_, err = kvg.NewFixer(&fixOpts).Fix(file, contents, &svg, []kvg.Edit{{
+		Description: "shift the types",
+		Apply: func(svg *kvg.SVG) error {
+			paths := svg.GetPaths()
+			for i := range paths {
+				if shifts[i] != i {
+					paths[i].Type = save[shifts[i]]
+				}
 			}
-		}
-		svg.WriteKanjiFile(file)
+			return nil
+		},
+	}})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
 	}
 }
 
@@ -86,7 +114,7 @@ type shiftInput struct {
 }
 
 func digitError(d string, err error) {
-	fmt.Fprintf(os.Stderr, "Error parsing digits %s: %s\n", err)
+	fmt.Fprintf(os.Stderr, "Error parsing digits %s: %s\n", d, err)
 	os.Exit(1)
 }
 
@@ -0,0 +1,309 @@
+package kvg
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// This is the heading of the combined kanjivg.xml file, which holds all
+// of the kanji in one file. It ends with the start tag of the kanjivg
+// element.
+var CombinedHeading = `<?xml version="1.0" encoding="UTF-8"?>
+<!--
+Copyright (C) 2009/2010/2011 Ulrich Apel.
+This work is distributed under the conditions of the Creative Commons
+Attribution-Share Alike 3.0 Licence. This means you are free:
+* to Share - to copy, distribute and transmit the work
+* to Remix - to adapt the work
+
+Under the following conditions:
+* Attribution. You must attribute the work by stating your use of KanjiVG in
+  your own copyright header and linking to KanjiVG's website
+  (http://kanjivg.tagaini.net)
+* Share Alike. If you alter, transform, or build upon this work, you may
+  distribute the resulting work only under the same or similar license to this
+  one.
+
+See http://creativecommons.org/licenses/by-sa/3.0/ for more details.
+-->
+<!DOCTYPE kanjivg [
+<!ELEMENT kanjivg (kanji*)>
+<!ATTLIST kanjivg
+xmlns:kvg CDATA #FIXED "http://kanjivg.tagaini.net">
+<!ELEMENT kanji (g*)>
+<!ATTLIST kanji
+id ID #REQUIRED>
+<!ELEMENT g (g|path|text)*>
+<!ATTLIST g
+id ID #REQUIRED
+style CDATA #IMPLIED
+kvg:element CDATA #IMPLIED
+kvg:variant CDATA #IMPLIED
+kvg:partial CDATA #IMPLIED
+kvg:original CDATA #IMPLIED
+kvg:part CDATA #IMPLIED
+kvg:number CDATA #IMPLIED
+kvg:tradForm CDATA #IMPLIED
+kvg:radicalForm CDATA #IMPLIED
+kvg:position CDATA #IMPLIED
+kvg:radical CDATA #IMPLIED
+kvg:phon CDATA #IMPLIED >
+<!ELEMENT path EMPTY>
+<!ATTLIST path
+id ID #REQUIRED
+d CDATA #REQUIRED
+kvg:type CDATA #IMPLIED >
+<!ELEMENT text (#PCDATA)>
+<!ATTLIST text
+transform CDATA #IMPLIED >
+]>
+` + combinedRoot
+
+// The start tag of the root element of the combined file.
+const combinedRoot = `<kanjivg xmlns:kvg="` + KVGNamespace + `">
+`
+
+// The styles of the StrokePaths and StrokeNumbers groups of the
+// KanjiVG files.
+const (
+	StrokePathsStyle   = "fill:none;stroke:#000000;stroke-width:3;stroke-linecap:round;stroke-linejoin:round;"
+	StrokeNumbersStyle = "font-size:8;fill:#808080"
+)
+
+// Make an SVG with the svg element and the StrokePaths group of the
+// KanjiVG files, and an empty base group with the id base, such as
+// "kvg:08475". There is no StrokeNumbers group. The error value wraps
+// ErrBadBase if base does not start with "kvg:".
+func NewSVG(base string) (svg SVG, err error) {
+	if !strings.HasPrefix(base, "kvg:") {
+		return svg, fmt.Errorf("%w: '%s'", ErrBadBase, base)
+	}
+	svg = SVG{
+		XMLNS:   SVGNamespace,
+		Width:   "109",
+		Height:  "109",
+		ViewBox: "0 0 109 109",
+		Groups: []Group{{
+			ID:       "kvg:StrokePaths_" + base[4:],
+			Style:    StrokePathsStyle,
+			Children: []Child{{IsGroup: true, Group: Group{ID: base}}},
+		}},
+	}
+	svg.Groups[0].linkParents()
+	return svg, nil
+}
+
+// A kanji read from the combined file.
+type CombinedKanji struct {
+	// The id of the kanji element without the "kvg:kanji_", such as
+	// "08475" or "08475-Kaisho".
+	ID string
+	// The kanji as it would be in its own file. If the combined file
+	// has stroke numbers, they are in the StrokeNumbers group.
+	SVG SVG
+}
+
+// The name of the file of the kanji, such as "08475-Kaisho.svg".
+func (k *CombinedKanji) Name() string {
+	return k.ID + ".svg"
+}
+
+// Reads the kanji of a combined kanjivg.xml file one at a time, so the
+// whole file does not need to be in memory.
+type CombinedReader struct {
+	rec *recorder
+	ps  parser
+}
+
+// Make a reader of the combined file in r.
+func NewCombinedReader(r io.Reader) *CombinedReader {
+	rec := &recorder{r: r}
+	return &CombinedReader{
+		rec: rec,
+		ps:  parser{d: xml.NewDecoder(rec), src: &rec.source},
+	}
+}
+
+// Read the next kanji. At the end of the file, the error value is
+// io.EOF.
+func (cr *CombinedReader) Next() (k *CombinedKanji, err error) {
+	for {
+		tok, raw, err := cr.ps.token()
+		if err != nil {
+			return nil, err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch el.Name.Local {
+			case "kanjivg":
+				continue
+			case "kanji":
+				k, err = cr.kanji(el, raw)
+				cr.rec.discard(cr.ps.d.InputOffset())
+				return k, err
+			}
+			return nil, fmt.Errorf("unexpected element %s", el.Name.Local)
+		case xml.EndElement:
+			if el.Name.Local == "kanjivg" {
+				return nil, io.EOF
+			}
+		}
+	}
+}
+
+// Read the kanji element started by start into a new SVG.
+func (cr *CombinedReader) kanji(start xml.StartElement, tag string) (k *CombinedKanji, err error) {
+	k = &CombinedKanji{}
+	for _, attr := range start.Attr {
+		if attr.Name == (xml.Name{Local: "id"}) {
+			k.ID = strings.TrimPrefix(attr.Value, "kvg:kanji_")
+		}
+	}
+	if len(k.ID) == 0 {
+		return nil, fmt.Errorf("%w: kanji element with no id", ErrBadID)
+	}
+	k.SVG, err = NewSVG("kvg:" + k.ID)
+	if err != nil {
+		return nil, err
+	}
+	var groups []Group
+	var f Format
+	err = cr.ps.contents(start, tag, &f, func(el xml.StartElement, raw string) (bool, string, error) {
+		if !isSVG(el.Name, "g") {
+			return cr.ps.spaceElement(el, raw)
+		}
+		var g Group
+		err := cr.ps.group(&g, el, raw)
+		if err != nil {
+			return false, "", err
+		}
+		groups = append(groups, g)
+		return true, "", nil
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("kanji %s: %w", k.ID, err)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("kanji %s: %w", k.ID, ErrNoBaseGroup)
+	}
+	k.SVG.Groups[0].Children[0].Group = groups[0]
+	k.SVG.Groups = append(k.SVG.Groups, groups[1:]...)
+	for i := range k.SVG.Groups {
+		k.SVG.Groups[i].linkParents()
+	}
+	return k, nil
+}
+
+// Options for CombinedWriter. The options of EncodeOptions apply to
+// each kanji, except that NoHeading leaves out CombinedHeading apart
+// from the start tag of the kanjivg element, so that the output is
+// still well-formed XML.
+type CombinedOptions struct {
+	EncodeOptions
+	// Put the StrokeNumbers group of each kanji after its base
+	// group. The combined file of KanjiVG does not have these, but
+	// without them the files made from the combined file have no
+	// stroke numbers.
+	StrokeNumbers bool
+}
+
+// Writes the combined kanjivg.xml file, one kanji at a time.
+type CombinedWriter struct {
+	w    io.Writer
+	opts CombinedOptions
+	// The first error, after which nothing more is written.
+	err     error
+	started bool
+}
+
+// Make a writer of a combined file to w, with the options opts, which
+// may be nil.
+func NewCombinedWriter(w io.Writer, opts *CombinedOptions) *CombinedWriter {
+	cw := &CombinedWriter{w: w}
+	if opts != nil {
+		cw.opts = *opts
+	}
+	return cw
+}
+
+// Write the string s, unless there has already been an error.
+func (cw *CombinedWriter) write(s string) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = io.WriteString(cw.w, s)
+}
+
+// Write the heading, if it has not been written yet.
+func (cw *CombinedWriter) start() {
+	if cw.started {
+		return
+	}
+	cw.started = true
+	if cw.opts.NoHeading {
+		cw.write(combinedRoot)
+	} else {
+		cw.write(CombinedHeading)
+	}
+}
+
+// Write the base group of kanjivg, and its stroke numbers if the
+// options say so, as a kanji element. The error value wraps
+// ErrNoBaseGroup or ErrMarshal as for MakeXML.
+func (cw *CombinedWriter) Write(kanjivg *SVG) (err error) {
+	cw.start()
+	if cw.err != nil {
+		return cw.err
+	}
+	if !kanjivg.HasBaseGroup() {
+		return ErrNoBaseGroup
+	}
+	if !cw.opts.NoRenumber {
+		kanjivg.renumberIDs()
+	}
+	if !cw.opts.NoRenumberLabels {
+		kanjivg.RenumberLabels()
+	}
+	_, id := kanjivg.Base()
+	var xw writer
+	xw.buf.WriteString(`<kanji id="kvg:kanji_`)
+	xw.escape(id)
+	xw.buf.WriteString(`">` + indent(1, false))
+	xw.group(kanjivg.BaseGroup(), 1)
+	if cw.opts.StrokeNumbers && len(kanjivg.Groups) > 1 {
+		xw.buf.WriteString(indent(1, false))
+		xw.group(&kanjivg.Groups[1], 1)
+	}
+	xw.buf.WriteString("\n</kanji>\n")
+	if xw.err != nil {
+		return xw.err
+	}
+	cw.write(xw.buf.String())
+	return cw.err
+}
+
+// Finish the combined file. This does not close the underlying
+// writer.
+func (cw *CombinedWriter) Close() (err error) {
+	cw.start()
+	cw.write("</kanjivg>\n")
+	return cw.err
+}
+
+// Write all of the files of the corpus to w as a combined file, in
+// order of file name. The error value is a FileErrors for the files
+// which could not be read or written.
+func (c *Corpus) WriteCombined(ctx context.Context, w io.Writer, opts *CombinedOptions) (err error) {
+	cw := NewCombinedWriter(w, opts)
+	err = c.Walk(ctx, &WalkOptions{Ordered: true}, func(kf *KanjiFile) error {
+		return cw.Write(&kf.SVG)
+	})
+	closeErr := cw.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
@@ -0,0 +1,106 @@
+package kvg
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCombined(t *testing.T) {
+	contents := read(bin() + "/t/08475.svg")
+	fsys := fstest.MapFS{
+		"08475.svg":        {Data: []byte(contents)},
+		"08475-Kaisho.svg": {Data: []byte(strings.ReplaceAll(contents, "08475", "08475-Kaisho"))},
+	}
+	var buf strings.Builder
+	err := NewCorpusFS(fsys).WriteCombined(context.Background(), &buf,
+		&CombinedOptions{StrokeNumbers: true, EncodeOptions: EncodeOptions{NoRenumber: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined := buf.String()
+	if !strings.HasPrefix(combined, CombinedHeading) ||
+		!strings.Contains(combined, "\n</kanji>\n<kanji id=\"kvg:kanji_08475\">\n<g id=\"kvg:08475\" kvg:element=\"葵\">\n") ||
+		!strings.HasSuffix(combined, "</kanjivg>\n") {
+		t.Errorf("Bad combined file:\n%s", combined)
+	}
+	cr := NewCombinedReader(strings.NewReader(combined))
+	cw := NewCombinedWriter(&buf, &CombinedOptions{StrokeNumbers: true})
+	buf.Reset()
+	var ids []string
+	for {
+		k, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, k.ID)
+		base := k.SVG.BaseGroup().Source()
+		if !strings.HasPrefix(combined[base.Offset:base.End], `<g id="kvg:`+k.ID+`"`) {
+			t.Errorf("Wrong source %s of %s", base, k.ID)
+		}
+		if k.ID == "08475" {
+			out, err := k.SVG.MakeXML()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != contents {
+				t.Errorf("File from combined file differs:\n%s", out)
+			}
+		}
+		err = cw.Write(&k.SVG)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = cw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != "08475-Kaisho" || ids[1] != "08475" {
+		t.Errorf("Bad kanji %q", ids)
+	}
+	if buf.String() != combined {
+		t.Errorf("Combined file differs after reading and writing:\n%s", buf.String())
+	}
+
+	// Without the heading, the file is still well-formed.
+	buf.Reset()
+	err = NewCorpusFS(fsys).WriteCombined(context.Background(), &buf,
+		&CombinedOptions{EncodeOptions: EncodeOptions{NoHeading: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "<kanjivg ") {
+		t.Errorf("Bad combined file with no heading:\n%s", buf.String())
+	}
+	d := xml.NewDecoder(strings.NewReader(buf.String()))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Combined file with no heading does not parse: %v", err)
+		}
+	}
+	cr = NewCombinedReader(strings.NewReader(buf.String()))
+	n := 0
+	for ; ; n++ {
+		_, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != 2 {
+		t.Errorf("Read %d kanji from combined file with no heading", n)
+	}
+}
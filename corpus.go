@@ -0,0 +1,231 @@
+package kvg
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A collection of KanjiVG files, such as the "kanji" directory of
+// KanjiVG. The files may be in a directory, in a zip archive, or in
+// any fs.FS, for example an embed.FS, so several corpora can be used
+// at once without changing KVDir.
+type Corpus struct {
+	fsys fs.FS
+	// The directory the corpus was made from, or the empty string if
+	// it was made from an fs.FS.
+	dir string
+	// The archive file the corpus was opened from, if any.
+	closer io.Closer
+}
+
+// Make a corpus from the KanjiVG files in the directory dir.
+func NewCorpus(dir string) *Corpus {
+	return &Corpus{fsys: os.DirFS(dir), dir: dir}
+}
+
+// Make a corpus from the KanjiVG files in fsys. The file names are the
+// names within fsys, for example "08475.svg".
+func NewCorpusFS(fsys fs.FS) *Corpus {
+	return &Corpus{fsys: fsys}
+}
+
+// Make a corpus from the KanjiVG files in the zip archive z. If the
+// archive has a "kanji" directory at the top, or within a single
+// directory at the top, as the KanjiVG releases do, the corpus is the
+// files in that directory. Otherwise it is the whole archive.
+func NewCorpusZip(z *zip.Reader) (c *Corpus, err error) {
+	var fsys fs.FS = z
+	for _, pattern := range []string{"kanji", "*/kanji"} {
+		dirs, err := fs.Glob(z, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(dirs) == 1 {
+			fsys, err = fs.Sub(z, dirs[0])
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return NewCorpusFS(fsys), nil
+}
+
+// Open the zip archive file, such as a KanjiVG release
+// kanjivg-20230110-all.zip, as a corpus. See NewCorpusZip. Call Close
+// when the corpus is no longer needed.
+func OpenCorpusZip(file string) (c *Corpus, err error) {
+	z, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	c, err = NewCorpusZip(&z.Reader)
+	if err != nil {
+		z.Close()
+		return nil, err
+	}
+	c.closer = z
+	return c, nil
+}
+
+// Open the corpus at path, which is either a directory or, if its
+// name ends in ".zip", a zip archive. Call Close when the corpus is no
+// longer needed.
+func OpenCorpus(path string) (c *Corpus, err error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return OpenCorpusZip(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory or a zip archive", path)
+	}
+	return NewCorpus(path), nil
+}
+
+// Close the archive which the corpus was opened from. This does
+// nothing for a directory or an fs.FS.
+func (c *Corpus) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer.Close()
+}
+
+// The file system which the corpus reads from.
+func (c *Corpus) FS() fs.FS {
+	return c.fsys
+}
+
+// The name of the file for kanji, with the variant, such as "Kaisho",
+// if variant is not the empty string. This is the name within the
+// corpus, so for example 葵 with no variant is "08475.svg".
+func FileName(kanji rune, variant string) (name string) {
+	name = fmt.Sprintf("%05x", kanji)
+	if len(variant) > 0 {
+		name += "-" + variant
+	}
+	return name + ".svg"
+}
+
+// The directory the corpus was made from, or the empty string if it
+// was made from an fs.FS or a zip archive, whose files cannot be
+// written.
+func (c *Corpus) Dir() string {
+	return c.dir
+}
+
+// The full path of the file called name within the corpus. If the
+// corpus is a directory, this is the name of the file on the
+// system. Otherwise it is just name.
+func (c *Corpus) Path(name string) string {
+	if len(c.dir) == 0 {
+		return name
+	}
+	return filepath.Join(c.dir, filepath.FromSlash(name))
+}
+
+// The name within the corpus of a file given by its full path. This
+// is the opposite of Path. Like TFile, but for any corpus.
+func (c *Corpus) Rel(file string) string {
+	if len(c.dir) == 0 {
+		return file
+	}
+	rel, err := filepath.Rel(c.dir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Is name the name of a KanjiVG file rather than a backup or
+// something else?
+func isKanjiName(name string) bool {
+	return strings.HasSuffix(name, ".svg") && !Backup.MatchString("/"+name)
+}
+
+// The names of all the KanjiVG files in the corpus, in lexical order,
+// leaving out backup files.
+func (c *Corpus) Files() (names []string, err error) {
+	err = fs.WalkDir(c.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isKanjiName(name) {
+			return nil
+		}
+		names = append(names, name)
+		return nil
+	})
+	return names, err
+}
+
+// The variants of kanji which exist in the corpus, with the empty
+// string for the file with no variant, in lexical order.
+func (c *Corpus) Variants(kanji rune) (variants []string, err error) {
+	names, err := fs.Glob(c.fsys, fmt.Sprintf("%05x*.svg", kanji))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		_, num, variant := FileToParts(name)
+		if rune(num) != kanji {
+			continue
+		}
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+	return variants, nil
+}
+
+// Read the file called name from the corpus.
+func (c *Corpus) ReadFile(name string) (contents []byte, err error) {
+	return fs.ReadFile(c.fsys, name)
+}
+
+// Read and parse the file called name from the corpus.
+func (c *Corpus) Read(name string) (svg SVG, err error) {
+	contents, err := c.ReadFile(name)
+	if err != nil {
+		return svg, err
+	}
+	svg, err = ParseKanji(contents)
+	if err != nil {
+		return svg, fmt.Errorf("%s: %w", c.Path(name), err)
+	}
+	return svg, nil
+}
+
+// Read and parse the file for kanji, with the variant, such as
+// "Kaisho", or the empty string for the usual form. If there is no
+// such file, the error value wraps fs.ErrNotExist.
+func (c *Corpus) Lookup(kanji rune, variant string) (svg SVG, err error) {
+	return c.Read(FileName(kanji, variant))
+}
+
+// Read and parse the file called name, for the walker, using cache if
+// it is not nil.
+func (c *Corpus) readKanjiFile(name string, cache *Cache) (kf *KanjiFile, err error) {
+	kf = &KanjiFile{Name: name, Path: c.Path(name)}
+	kf.Contents, err = c.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		kf.SVG, err = cache.Parse(name, kf.Contents)
+	} else {
+		kf.SVG, err = ParseKanji(kf.Contents)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kf, nil
+}
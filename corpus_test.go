@@ -0,0 +1,201 @@
+package kvg
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func testFS() fstest.MapFS {
+	contents := []byte(read(bin() + "/t/08475.svg"))
+	return fstest.MapFS{
+		"08475.svg":        {Data: contents},
+		"08475-Kaisho.svg": {Data: contents},
+		"08475.svg~":       {Data: []byte("<svg")},
+		"README.md":        {Data: []byte("Not a kanji")},
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	c := NewCorpusFS(testFS())
+	files, err := c.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0] != "08475-Kaisho.svg" || files[1] != "08475.svg" {
+		t.Errorf("Bad file list %v", files)
+	}
+	variants, err := c.Variants('葵')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 2 || variants[0] != "" || variants[1] != "Kaisho" {
+		t.Errorf("Bad variants %q", variants)
+	}
+	svg, err := c.Lookup('葵', "Kaisho")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svg.GetPaths()) != 12 {
+		t.Errorf("Wrong number of paths in looked-up kanji")
+	}
+	_, err = c.Lookup('葵', "Jinmei")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected not-exist error, got %v", err)
+	}
+	n := 0
+	err = c.Walk(context.Background(), &WalkOptions{Ordered: true}, func(kf *KanjiFile) error {
+		if kf.Name != files[n] || kf.Path != files[n] {
+			t.Errorf("Walk gave %s/%s, expected %s", kf.Name, kf.Path, files[n])
+		}
+		n++
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Errorf("Walk gave %d files, error %v", n, err)
+	}
+	dir := NewCorpus(bin() + "/t")
+	path := dir.Path("08475.svg")
+	if path != filepath.Join(bin(), "t", "08475.svg") {
+		t.Errorf("Bad path %s", path)
+	}
+	if dir.Rel(path) != "08475.svg" {
+		t.Errorf("Bad relative name %s", dir.Rel(path))
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ix, err := NewCorpusFS(testFS()).Index(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != 2 {
+		t.Fatalf("Expected 2 entries, got %d", ix.Len())
+	}
+	e := ix.Get('葵', "")
+	if e == nil || e.Name != "08475.svg" || e.Strokes != 12 {
+		t.Fatalf("Bad entry %+v", e)
+	}
+	tests := []struct {
+		name string
+		got  []*IndexEntry
+		n    int
+	}{
+		{"element", ix.WithElement("天", ""), 2},
+		{"element at position", ix.WithElement("癶", "top"), 2},
+		{"element at wrong position", ix.WithElement("癶", "left"), 0},
+		{"original element", ix.WithElement("艸", "top"), 2},
+		{"base element", ix.WithElement("葵", ""), 0},
+		{"radical", ix.WithRadical("general", "艹"), 2},
+		{"any radical", ix.WithRadical("", "艸"), 2},
+		{"nelson radical", ix.WithRadical("nelson", "艹"), 0},
+		{"strokes", ix.WithStrokes(12), 2},
+		{"wrong strokes", ix.WithStrokes(11), 0},
+		{"stroke type", ix.WithStrokeType("㇑a"), 2},
+		{"stroke type without suffix", ix.WithStrokeType("㇑"), 2},
+		{"missing stroke type", ix.WithStrokeType("㇆"), 0},
+	}
+	for _, test := range tests {
+		if len(test.got) != test.n {
+			t.Errorf("%s: expected %d, got %d", test.name, test.n, len(test.got))
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kvg.cache")
+	fsys := testFS()
+	corpus := NewCorpusFS(fsys)
+	for i := 0; i < 3; i++ {
+		cache, err := OpenCache(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ix, err := corpus.Index(context.Background(), &WalkOptions{Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cache.Save()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The first time everything is parsed, the second time
+		// nothing is, and the third time the altered file is.
+		hits := []int{0, 2, 1}[i]
+		if cache.hits != hits || cache.misses != 2-hits {
+			t.Errorf("Round %d: expected %d hits, got %d hits %d misses",
+				i, hits, cache.hits, cache.misses)
+		}
+		e := ix.Get('葵', "Kaisho")
+		if e == nil || e.Strokes != 12 || len(ix.WithElement("癶", "top")) != 2 {
+			t.Errorf("Round %d: bad index from cache", i)
+			continue
+		}
+		paths := e.SVG.GetPaths()
+		if paths[0].Parent == nil || paths[0].Parent.Parent.Element != "艹" {
+			t.Errorf("Round %d: parents not linked", i)
+		}
+		if i == 1 {
+			data := append([]byte(nil), fsys["08475.svg"].Data...)
+			data = bytes.Replace(data, []byte("M20.5,23.7"), []byte("M20.5,23.8"), 1)
+			fsys["08475.svg"] = &fstest.MapFile{Data: data}
+		}
+	}
+}
+
+func TestZipCorpus(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "kanjivg-20230110-all.zip")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(out)
+	for name, f := range testFS() {
+		w, err := zw.Create("kanjivg-20230110/kanji/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(f.Data)
+	}
+	err = zw.Close()
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := OpenCorpus(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	files, err := c.Files()
+	if err != nil || len(files) != 2 || files[0] != "08475-Kaisho.svg" {
+		t.Fatalf("Bad files %v from zip, error %v", files, err)
+	}
+	if len(c.Dir()) != 0 || c.Path(files[0]) != files[0] {
+		t.Errorf("Zip corpus has directory %q", c.Dir())
+	}
+	_, num, variant := FileToParts(files[0])
+	if num != 0x8475 || variant != "Kaisho" {
+		t.Errorf("Bad parts %x %s", num, variant)
+	}
+	svg, err := c.Lookup('葵', "Kaisho")
+	if err != nil || len(svg.GetPaths()) != 12 {
+		t.Errorf("Lookup in zip failed: %v", err)
+	}
+	n := 0
+	err = c.Walk(context.Background(), nil, func(kf *KanjiFile) error {
+		n++
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Errorf("Walk of zip gave %d files, error %v", n, err)
+	}
+}
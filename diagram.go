@@ -0,0 +1,266 @@
+package kvg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+)
+
+// The formats of DiagramOptions.
+type DiagramFormat int
+
+const (
+	// One SVG with the frames side by side.
+	DiagramSVG DiagramFormat = iota
+	// One PNG with the frames side by side.
+	DiagramPNG
+	// An animated GIF with one frame after the other.
+	DiagramGIF
+)
+
+// Options for Diagram. The zero value makes one row of frames in SVG at
+// the size of the file, with the strokes in the colour of the file and
+// the newest stroke in red.
+type DiagramOptions struct {
+	Format DiagramFormat
+	// The number of frames in each row of an SVG or PNG. If this is
+	// zero, all the frames are in one row.
+	Columns int
+	// The width and height of each frame in pixels. If this is zero,
+	// the width of the file is used.
+	Size int
+	// The colour of the strokes before the newest one. If this is nil,
+	// the stroke colour of the file is used.
+	Color color.Color
+	// The colour of the newest stroke. If this is nil, a dark red is
+	// used.
+	Highlight color.Color
+	// If this is not nil, the strokes still to be drawn are shown in
+	// this colour.
+	Ghost color.Color
+	// The background colour. If this is nil, the background of an SVG
+	// or PNG is transparent, and that of a GIF is white.
+	Background color.Color
+	// The time each frame of a GIF is shown, in seconds. If this is
+	// zero, half a second is used.
+	Delay float64
+}
+
+// The colour of the newest stroke if DiagramOptions.Highlight is nil.
+var defaultHighlight = color.RGBA{0xc0, 0, 0, 0xff}
+
+// Fill in the defaults of opts for kanjivg, returning a copy.
+func (opts DiagramOptions) defaults(kanjivg *SVG) DiagramOptions {
+	if opts.Size <= 0 {
+		opts.Size = int(math.Round(kanjivg.viewBox().Width()))
+	}
+	if opts.Color == nil {
+		opts.Color = styleColor(kanjivg.Groups[0].Style, "stroke", color.Black)
+	}
+	if opts.Highlight == nil {
+		opts.Highlight = defaultHighlight
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = 0.5
+	}
+	return opts
+}
+
+// The colour of stroke i in frame k of a diagram, the frame of the
+// stroke with index k. If ghosts is true, only the ghosts are given,
+// so they can be drawn underneath the others.
+func (opts *DiagramOptions) strokeColor(i, k int, ghosts bool) color.Color {
+	switch {
+	case i > k:
+		if ghosts {
+			return opts.Ghost
+		}
+		return nil
+	case ghosts:
+		return nil
+	case i == k:
+		return opts.Highlight
+	}
+	return opts.Color
+}
+
+// The number of columns and rows of a diagram of n frames.
+func (opts *DiagramOptions) grid(n int) (cols, rows int) {
+	cols = opts.Columns
+	if cols <= 0 || cols > n {
+		cols = n
+	}
+	if cols == 0 {
+		return 0, 0
+	}
+	return cols, (n + cols - 1) / cols
+}
+
+// Write a diagram of the stroke order of kanjivg to w. There is one
+// frame for each stroke, showing the strokes up to it, with the newest
+// one highlighted. The error is a PathError if a stroke cannot be
+// parsed, or ErrNoBaseGroup, or is from w.
+func Diagram(w io.Writer, kanjivg *SVG, opts *DiagramOptions) (err error) {
+	if opts == nil {
+		opts = &DiagramOptions{}
+	}
+	if !kanjivg.HasBaseGroup() {
+		return ErrNoBaseGroup
+	}
+	o := opts.defaults(kanjivg)
+	switch o.Format {
+	case DiagramSVG:
+		return diagramSVG(w, kanjivg, &o)
+	case DiagramPNG:
+		img, err := diagramImage(kanjivg, &o)
+		if err != nil {
+			return err
+		}
+		return png.Encode(w, img)
+	case DiagramGIF:
+		return diagramGIF(w, kanjivg, &o)
+	}
+	return fmt.Errorf("unknown diagram format %d", o.Format)
+}
+
+// The colour c in the form "#rrggbb", ignoring its alpha.
+func colorHex(c color.Color) string {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return "none"
+	}
+	// Undo the premultiplication.
+	un := func(v uint32) uint32 {
+		return v * 0xff / a
+	}
+	return fmt.Sprintf("#%02x%02x%02x", un(r), un(g), un(b))
+}
+
+// Write the diagram as an SVG with the frames side by side.
+func diagramSVG(w io.Writer, kanjivg *SVG, opts *DiagramOptions) (err error) {
+	paths := kanjivg.GetPaths()
+	box := kanjivg.viewBox()
+	size := float64(opts.Size)
+	s := size / box.Width()
+	cols, rows := opts.grid(len(paths))
+	width := fmt.Sprint(cols * opts.Size)
+	height := fmt.Sprint(rows * opts.Size)
+	var sw svgWriter
+	sw.tag("svg", false, "xmlns", SVGNamespace, "width", width, "height", height,
+		"viewBox", "0 0 "+width+" "+height)
+	sw.WriteString("\n")
+	if opts.Background != nil {
+		sw.tag("rect", true, "width", width, "height", height, "fill", colorHex(opts.Background))
+		sw.WriteString("\n")
+	}
+	style := kanjivg.Groups[0].Style
+	for k := range paths {
+		x, y := float64(k%cols)*size, float64(k/cols)*size
+		m := Translate(-box.Min.X, -box.Min.Y).
+			Then(Scale(s, s, Point{})).
+			Then(Translate(x, y))
+		sw.tag("g", false, "id", fmt.Sprintf("kvg:StrokeOrder-%d", k+1), "style", style,
+			"transform", fmt.Sprintf("matrix(%s %s %s %s %s %s)", animNumber(m.A), animNumber(m.B),
+				animNumber(m.C), animNumber(m.D), animNumber(m.E), animNumber(m.F)))
+		sw.WriteString("\n")
+		for _, ghosts := range []bool{true, false} {
+			for i, p := range paths {
+				c := opts.strokeColor(i, k, ghosts)
+				if c == nil {
+					continue
+				}
+				sw.WriteString("\t")
+				sw.tag("path", true, "d", p.D, "stroke", colorHex(c))
+				sw.WriteString("\n")
+			}
+		}
+		sw.WriteString("</g>\n")
+	}
+	sw.WriteString("</svg>\n")
+	_, err = sw.WriteTo(w)
+	return err
+}
+
+// Draw the frame of the stroke with index k in the area dst of img.
+func diagramFrame(img *image.RGBA, dst image.Rectangle, kanjivg *SVG, opts *DiagramOptions, k int) (err error) {
+	if opts.Background != nil {
+		draw.Draw(img, dst, image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	ro := &RenderOptions{}
+	for _, ghosts := range []bool{true, false} {
+		err = drawKanji(img, dst, kanjivg, ro, func(i int) color.Color {
+			return opts.strokeColor(i, k, ghosts)
+		}, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Draw the diagram as one image with the frames side by side.
+func diagramImage(kanjivg *SVG, opts *DiagramOptions) (img *image.RGBA, err error) {
+	n := len(kanjivg.GetPaths())
+	cols, rows := opts.grid(n)
+	img = image.NewRGBA(image.Rect(0, 0, cols*opts.Size, rows*opts.Size))
+	for k := 0; k < n; k++ {
+		at := image.Pt(k%cols, k/cols).Mul(opts.Size)
+		dst := image.Rectangle{at, at.Add(image.Pt(opts.Size, opts.Size))}
+		err = diagramFrame(img, dst, kanjivg, opts, k)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// The number of shades of each colour in the palette of a GIF.
+const gifShades = 32
+
+// Make the palette of a GIF, with shades between the background and
+// each colour of the strokes.
+func diagramPalette(opts *DiagramOptions) (p color.Palette) {
+	bg := color.RGBAModel.Convert(opts.Background).(color.RGBA)
+	p = color.Palette{bg}
+	for _, c := range []color.Color{opts.Color, opts.Highlight, opts.Ghost} {
+		if c == nil {
+			continue
+		}
+		fg := color.RGBAModel.Convert(c).(color.RGBA)
+		for i := 1; i <= gifShades; i++ {
+			mix := func(a, b uint8) uint8 {
+				return uint8((int(a)*(gifShades-i) + int(b)*i) / gifShades)
+			}
+			p = append(p, color.RGBA{mix(bg.R, fg.R), mix(bg.G, fg.G), mix(bg.B, fg.B), 0xff})
+		}
+	}
+	return p
+}
+
+// Write the diagram as an animated GIF, one frame after the other.
+func diagramGIF(w io.Writer, kanjivg *SVG, opts *DiagramOptions) (err error) {
+	if opts.Background == nil {
+		opts.Background = color.White
+	}
+	palette := diagramPalette(opts)
+	bounds := image.Rect(0, 0, opts.Size, opts.Size)
+	delay := int(math.Round(opts.Delay * 100))
+	var anim gif.GIF
+	for k := range kanjivg.GetPaths() {
+		frame := image.NewRGBA(bounds)
+		err = diagramFrame(frame, bounds, kanjivg, opts, k)
+		if err != nil {
+			return err
+		}
+		pal := image.NewPaletted(bounds, palette)
+		draw.Draw(pal, bounds, frame, image.Point{}, draw.Src)
+		anim.Image = append(anim.Image, pal)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, &anim)
+}
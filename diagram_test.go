@@ -0,0 +1,91 @@
+package kvg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestDiagram(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grey := color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	var out bytes.Buffer
+	err = Diagram(&out, &svg, &DiagramOptions{Columns: 4, Ghost: grey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	d := xml.NewDecoder(&out)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			counts[el.Name.Local]++
+			if el.Name.Local == "svg" {
+				for _, a := range el.Attr {
+					if a.Name.Local == "width" && a.Value != "436" {
+						t.Errorf("Width is %s", a.Value)
+					}
+				}
+			}
+		}
+	}
+	if counts["g"] != 12 || counts["path"] != 144 {
+		t.Errorf("Wrong elements %v", counts)
+	}
+
+	out.Reset()
+	err = Diagram(&out, &svg, &DiagramOptions{Format: DiagramPNG, Columns: 5, Size: 218})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 1090, 654) {
+		t.Fatalf("Wrong size %v", img.Bounds())
+	}
+	path, err := svg.GetPaths()[0].SVGPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := path.PointAt(0.5).scale(2)
+	// The first stroke is highlighted in the first frame only.
+	for k, want := range []color.Color{defaultHighlight, color.Black} {
+		c := color.RGBAModel.Convert(img.At(k*218+int(p.X), int(p.Y)))
+		if c != color.RGBAModel.Convert(want) {
+			t.Errorf("Frame %d: first stroke is %v", k+1, c)
+		}
+	}
+
+	out.Reset()
+	err = Diagram(&out, &svg, &DiagramOptions{Format: DiagramGIF, Size: 80, Delay: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 12 || anim.Delay[0] != 100 || anim.Image[0].Bounds().Dx() != 80 {
+		t.Errorf("Wrong GIF: %d frames, delay %d", len(anim.Image), anim.Delay[0])
+	}
+
+	if err = Diagram(&out, &SVG{}, nil); err != ErrNoBaseGroup {
+		t.Errorf("Wrong error %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package kvg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// The number of unchanged lines shown around each change by
+// UnifiedDiff.
+const diffContext = 3
+
+// Split b into lines, each with its newline except perhaps the last.
+func splitLines(b []byte) (lines []string) {
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, string(b[:i]))
+		b = b[i:]
+	}
+	return lines
+}
+
+// One line of a diff, which is ' ' if it is in both, '-' if it is only
+// in the old file, or '+' if it is only in the new file.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Find the lines which differ between a and b, using the longest
+// common subsequence of what is left after taking away the lines
+// which are the same at the start and the end.
+func diffLines(a, b []string) (lines []diffLine) {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre &&
+		a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	for _, l := range a[:pre] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	ma, mb := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	// lcs[i][j] is the length of the longest common subsequence of
+	// ma[i:] and mb[j:].
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		switch {
+		case i < len(ma) && j < len(mb) && ma[i] == mb[j]:
+			lines = append(lines, diffLine{' ', ma[i]})
+			i++
+			j++
+		case j == len(mb) || (i < len(ma) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', ma[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', mb[j]})
+			j++
+		}
+	}
+	for _, l := range a[len(a)-suf:] {
+		lines = append(lines, diffLine{' ', l})
+	}
+	return lines
+}
+
+// The start and length of a hunk in one of the files, as written in
+// the "@@" line of a unified diff.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		// An empty range is given by the line before it.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, n)
+}
+
+// Write the differences between a, the old contents of a file called
+// oldName, and b, its new contents called newName, to w as a unified
+// diff, as given by "diff -u", which patch and git apply understand.
+// Nothing is written if a and b are the same.
+func UnifiedDiff(w io.Writer, oldName, newName string, a, b []byte) (err error) {
+	lines := diffLines(splitLines(a), splitLines(b))
+	bw := bufio.NewWriter(w)
+	header := false
+	// The line numbers in the old and new files of lines[i].
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// Find the end of the hunk, which goes on until there are
+		// more than twice the context of unchanged lines.
+		end := i
+		for k := i; k < len(lines) && k-end <= 2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			}
+		}
+		begin := i - diffContext
+		if begin < 0 {
+			begin = 0
+		}
+		last := end + diffContext
+		if last > len(lines) {
+			last = len(lines)
+		}
+		oldStart, newStart := oldLine-(i-begin), newLine-(i-begin)
+		nOld, nNew := 0, 0
+		for _, l := range lines[begin:last] {
+			if l.op != '+' {
+				nOld++
+			}
+			if l.op != '-' {
+				nNew++
+			}
+		}
+		if !header {
+			fmt.Fprintf(bw, "--- %s\n+++ %s\n", oldName, newName)
+			header = true
+		}
+		fmt.Fprintf(bw, "@@ -%s +%s @@\n", hunkRange(oldStart, nOld), hunkRange(newStart, nNew))
+		for _, l := range lines[begin:last] {
+			bw.WriteByte(l.op)
+			bw.WriteString(l.text)
+			if len(l.text) == 0 || l.text[len(l.text)-1] != '\n' {
+				bw.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		oldLine, newLine = oldStart+nOld, newStart+nNew
+		i = last
+	}
+	return bw.Flush()
+}
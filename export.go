@@ -0,0 +1,309 @@
+package kvg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// One stroke of a kanji as a list of points, for exporting to other
+// programs, for example to train handwriting recognition.
+type StrokeRecord struct {
+	// The kanji, as a Unicode code point.
+	Kanji rune `json:"kanji"`
+	// The variant part of the file name, such as "Kaisho", or the
+	// empty string.
+	Variant string `json:"variant"`
+	// The number of the stroke, starting from one.
+	Stroke int `json:"stroke"`
+	// The kvg:type of the stroke, such as "㇐".
+	Type string `json:"type"`
+	// The kvg:element values of the groups containing the stroke,
+	// from the base group inwards. Groups with no element are left
+	// out.
+	Elements []string `json:"elements"`
+	// The points along the stroke, each an x, y pair.
+	Points [][2]float64 `json:"points"`
+}
+
+// The formats of ExportOptions.
+type ExportFormat int
+
+const (
+	// One JSON object per line, as in StrokeRecord.
+	ExportJSONLines ExportFormat = iota
+	// The binary format described at StrokeWriter.
+	ExportBinary
+)
+
+// Options for exporting strokes.
+type ExportOptions struct {
+	// The number of points for each stroke, equally spaced along it.
+	// If this is zero, the strokes are flattened to within Tolerance
+	// instead, so the number of points varies.
+	Points int
+	// How far the lines between the points may be from the curve of
+	// the stroke, if Points is zero. If this is also zero, 0.1 is
+	// used.
+	Tolerance float64
+	// The format to write.
+	Format ExportFormat
+	// If this is not nil, only the files for which it returns true
+	// are written by Corpus.ExportStrokes.
+	Filter func(kf *KanjiFile) bool
+}
+
+// Make the records of the strokes of svg, which was read from the file
+// called name. The error is from parsing the 'd' attribute of a
+// stroke, which is a PathError.
+func StrokeRecords(name string, svg *SVG, opts *ExportOptions) (records []StrokeRecord, err error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	_, num, variant := FileToParts(name)
+	for i, p := range svg.GetPaths() {
+		path, err := p.SVGPath()
+		if err != nil {
+			return nil, &PathError{ID: p.ID, Err: err}
+		}
+		var points []Point
+		if opts.Points > 0 {
+			points = path.Resample(opts.Points)
+		} else {
+			tolerance := opts.Tolerance
+			if tolerance <= 0 {
+				tolerance = 0.1
+			}
+			points = path.Flatten(tolerance)
+		}
+		r := StrokeRecord{
+			Kanji:    rune(num),
+			Variant:  variant,
+			Stroke:   i + 1,
+			Type:     p.Type,
+			Elements: elementChain(p),
+			Points:   make([][2]float64, len(points)),
+		}
+		for j, pt := range points {
+			r.Points[j] = [2]float64{pt.X, pt.Y}
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// The elements of the groups containing p, from the outside inwards,
+// stopping before the StrokePaths group.
+func elementChain(p *Path) (elements []string) {
+	elements = []string{}
+	if p.Parent == nil {
+		return elements
+	}
+	for g := p.Parent.Parent; g != nil && g.Parent != nil; g = g.Parent.Parent {
+		if len(g.Element) > 0 {
+			elements = append([]string{g.Element}, elements...)
+		}
+	}
+	return elements
+}
+
+// The start of a file of strokes in the binary format.
+const strokeMagic = "KVGS\x01"
+
+// Writes stroke records as JSON Lines or in a binary format.
+//
+// The binary format starts with the five bytes "KVGS\x01", and then
+// each record is, with all numbers little-endian: the code point as a
+// uint32; the variant; the stroke number as a uint16; the type; the
+// number of elements as a uint8, followed by each element; the number
+// of points as a uint16; and the points, as float32 x and y. Each
+// string, the variant, type and elements, is a uint8 length followed
+// by that many bytes of UTF-8.
+type StrokeWriter struct {
+	w       *bufio.Writer
+	format  ExportFormat
+	started bool
+}
+
+// Make a writer of stroke records to w in format.
+func NewStrokeWriter(w io.Writer, format ExportFormat) *StrokeWriter {
+	return &StrokeWriter{w: bufio.NewWriter(w), format: format}
+}
+
+// Write one record. In the binary format, a record with too many
+// elements or points, or a string of more than 255 bytes, is an error.
+func (sw *StrokeWriter) Write(r *StrokeRecord) (err error) {
+	if sw.format == ExportJSONLines {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = sw.w.Write(append(b, '\n'))
+		return err
+	}
+	if !sw.started {
+		sw.started = true
+		_, err = sw.w.WriteString(strokeMagic)
+		if err != nil {
+			return err
+		}
+	}
+	if len(r.Elements) > math.MaxUint8 || len(r.Points) > math.MaxUint16 {
+		return fmt.Errorf("too many elements or points in stroke %d of %c", r.Stroke, r.Kanji)
+	}
+	for _, s := range append([]string{r.Variant, r.Type}, r.Elements...) {
+		if len(s) > math.MaxUint8 {
+			return fmt.Errorf("string too long in stroke %d of %c", r.Stroke, r.Kanji)
+		}
+	}
+	var buf bytes.Buffer
+	str := func(s string) {
+		buf.WriteByte(byte(len(s)))
+		buf.WriteString(s)
+	}
+	le := binary.LittleEndian
+	binary.Write(&buf, le, uint32(r.Kanji))
+	str(r.Variant)
+	binary.Write(&buf, le, uint16(r.Stroke))
+	str(r.Type)
+	buf.WriteByte(byte(len(r.Elements)))
+	for _, e := range r.Elements {
+		str(e)
+	}
+	binary.Write(&buf, le, uint16(len(r.Points)))
+	for _, p := range r.Points {
+		binary.Write(&buf, le, [2]float32{float32(p[0]), float32(p[1])})
+	}
+	_, err = sw.w.Write(buf.Bytes())
+	return err
+}
+
+// Write out anything which is waiting to be written. Call this at the
+// end.
+func (sw *StrokeWriter) Flush() error {
+	return sw.w.Flush()
+}
+
+// Read the strokes in the binary format written by StrokeWriter from
+// r, calling fn for each one.
+func ReadStrokes(r io.Reader, fn func(r *StrokeRecord) error) (err error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(strokeMagic))
+	_, err = io.ReadFull(br, magic)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if string(magic) != strokeMagic {
+		return fmt.Errorf("not a file of strokes")
+	}
+	for {
+		var rec StrokeRecord
+		var code uint32
+		err = binary.Read(br, binary.LittleEndian, &code)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rec.Kanji = rune(code)
+		err = readStroke(br, &rec)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(&rec)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Read the rest of a record after the code point.
+func readStroke(br *bufio.Reader, rec *StrokeRecord) (err error) {
+	str := func() (s string) {
+		if err != nil {
+			return ""
+		}
+		var n byte
+		n, err = br.ReadByte()
+		if err != nil {
+			return ""
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(br, b)
+		return string(b)
+	}
+	var u16 uint16
+	rec.Variant = str()
+	if err == nil {
+		err = binary.Read(br, binary.LittleEndian, &u16)
+		rec.Stroke = int(u16)
+	}
+	rec.Type = str()
+	var ne byte
+	if err == nil {
+		ne, err = br.ReadByte()
+	}
+	rec.Elements = []string{}
+	for i := 0; i < int(ne) && err == nil; i++ {
+		rec.Elements = append(rec.Elements, str())
+	}
+	if err == nil {
+		err = binary.Read(br, binary.LittleEndian, &u16)
+	}
+	if err != nil {
+		return err
+	}
+	xy := make([]float32, 2*int(u16))
+	err = binary.Read(br, binary.LittleEndian, xy)
+	if err != nil {
+		return err
+	}
+	rec.Points = make([][2]float64, u16)
+	for i := range rec.Points {
+		rec.Points[i] = [2]float64{float64(xy[2*i]), float64(xy[2*i+1])}
+	}
+	return nil
+}
+
+// Write the strokes of each file of the corpus accepted by
+// opts.Filter to w, in order of file name. The error value is a
+// FileErrors for the files which could not be read or written.
+func (c *Corpus) ExportStrokes(ctx context.Context, w io.Writer, opts *ExportOptions) (err error) {
+	if opts == nil {
+		opts = &ExportOptions{}
+	}
+	sw := NewStrokeWriter(w, opts.Format)
+	err = c.Walk(ctx, &WalkOptions{Ordered: true}, func(kf *KanjiFile) error {
+		if opts.Filter != nil && !opts.Filter(kf) {
+			return nil
+		}
+		records, err := StrokeRecords(kf.Name, &kf.SVG, opts)
+		if err != nil {
+			return err
+		}
+		for i := range records {
+			err = sw.Write(&records[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	flushErr := sw.Flush()
+	if err != nil {
+		return err
+	}
+	return flushErr
+}
@@ -0,0 +1,174 @@
+package kvg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// A change to a KanjiVG file, such as the fix of a lint issue or a
+// step of a script.
+type Edit struct {
+	// What the edit does, such as "remove the position".
+	Description string
+	// Make the change to svg.
+	Apply func(svg *SVG) error
+}
+
+// Options for a Fixer.
+type FixOptions struct {
+	// Do not write the files, only work out which would change.
+	DryRun bool
+	// If this is not nil, a unified diff of each file which is
+	// changed, or would be changed in a dry run, is written to it.
+	Diff io.Writer
+	// Keep the old file, with "~" added to its name. The name matches
+	// Backup, so the backups are not read as part of a corpus.
+	Backup bool
+	// Write the files in the style of the KanjiVG files, as if
+	// ClearFormat had been called, rather than keeping their format.
+	ClearFormat bool
+	// How to write the files.
+	EncodeOptions
+}
+
+// Applies edits to KanjiVG files and writes them back. A Fixer must
+// not be used from more than one goroutine at once.
+type Fixer struct {
+	opts FixOptions
+	// The number of files changed, or which would have been changed
+	// in a dry run.
+	Changed int
+}
+
+// Make a fixer. If opts is nil, the files are written, keeping their
+// format, with no backups.
+func NewFixer(opts *FixOptions) *Fixer {
+	if opts == nil {
+		opts = &FixOptions{}
+	}
+	return &Fixer{opts: *opts}
+}
+
+// Apply the edits, in order, to svg, which was read from file, whose
+// contents were contents, and write the result to file if it differs
+// from contents. If contents is nil, it is read from file, and if svg
+// is nil, it is parsed from contents. The edits alter svg. The return
+// value says whether the file changed, or would have in a dry run.
+// Nothing is written if an edit fails.
+func (fx *Fixer) Fix(file string, contents []byte, svg *SVG, edits []Edit) (changed bool, err error) {
+	if contents == nil {
+		contents, err = os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+	}
+	if svg == nil {
+		parsed, err := ParseKanji(contents)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", file, err)
+		}
+		svg = &parsed
+	}
+	for _, e := range edits {
+		err = e.Apply(svg)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s: %w", file, e.Description, err)
+		}
+	}
+	if fx.opts.ClearFormat {
+		svg.ClearFormat()
+	}
+	var buf bytes.Buffer
+	err = Encode(&buf, svg, &fx.opts.EncodeOptions)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", file, err)
+	}
+	output := buf.Bytes()
+	if bytes.Equal(output, contents) {
+		return false, nil
+	}
+	fx.Changed++
+	if fx.opts.Diff != nil {
+		err = UnifiedDiff(fx.opts.Diff, file, file, contents, output)
+		if err != nil {
+			return true, err
+		}
+	}
+	if fx.opts.DryRun {
+		return true, nil
+	}
+	return true, WriteFileAtomic(file, output, fx.opts.Backup)
+}
+
+// Write data to file by writing it to a temporary file in the same
+// directory and renaming that to file, so that file is never left
+// half written. The temporary file's name matches Backup. If file
+// exists, its permissions are kept, and if backup is true, the old
+// file is kept with "~" added to its name.
+func WriteFileAtomic(file string, data []byte, backup bool) (err error) {
+	mode := fs.FileMode(0644)
+	info, err := os.Stat(file)
+	exists := err == nil
+	if exists {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	// The temporary file is in the same directory, so that renaming
+	// it is atomic. This is "." for a file name with no directory.
+	dir, base := filepath.Dir(file), filepath.Base(file)
+	tmp, err := os.CreateTemp(dir, ".#"+base+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+	if backup && exists {
+		err = keepBackup(file)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Keep the file as it is now as file~, replacing any old backup. This
+// links the backup to the file if it can, so that the backup is exactly
+// the old file, and copies it otherwise.
+func keepBackup(file string) error {
+	backup := file + "~"
+	err := os.Remove(backup)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if os.Link(file, backup) == nil {
+		return nil
+	}
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(backup, contents, 0644)
+}
@@ -0,0 +1,120 @@
+package kvg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFix(t *testing.T) {
+	contents := read(bin() + "/t/08475.svg")
+	dir := t.TempDir()
+	file := filepath.Join(dir, "08475.svg")
+	err := os.WriteFile(file, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits := []Edit{{
+		Description: "add a position",
+		Apply: func(svg *SVG) error {
+			svg.BaseGroup().Position = "top"
+			return nil
+		},
+	}}
+	var diff strings.Builder
+	fx := NewFixer(&FixOptions{DryRun: true, Diff: &diff})
+	changed, err := fx.Fix(file, nil, nil, edits)
+	if err != nil || !changed || fx.Changed != 1 {
+		t.Fatalf("Dry run did not change the file: %v", err)
+	}
+	// The base group is on line 39, so the hunk starts three lines
+	// before it.
+	got := diff.String()
+	if !strings.HasPrefix(got, "--- "+file+"\n+++ "+file+"\n@@ -36,7 +36,7 @@\n") ||
+		!strings.Contains(got, "\n-<g id=\"kvg:08475\" kvg:element=\"葵\">\n"+
+			"+<g id=\"kvg:08475\" kvg:element=\"葵\" kvg:position=\"top\">\n") ||
+		strings.Count(got, "\n") != 3+7+1 {
+		t.Errorf("Wrong diff:\n%s", got)
+	}
+	if read(file) != contents {
+		t.Errorf("Dry run wrote the file")
+	}
+
+	fx = NewFixer(&FixOptions{Backup: true})
+	changed, err = fx.Fix(file, nil, nil, edits)
+	if err != nil || !changed {
+		t.Fatalf("File not changed: %v", err)
+	}
+	if !strings.Contains(read(file), `kvg:position="top"`) || read(file+"~") != contents {
+		t.Errorf("Wrong file or backup")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("Temporary file left behind: %v", entries)
+	}
+	changed, err = fx.Fix(file, nil, nil, edits)
+	if err != nil || changed {
+		t.Errorf("File changed again: %v", err)
+	}
+	if !Backup.MatchString(file+"~") || !Backup.MatchString(dir+"/.#08475.svg.123") {
+		t.Errorf("Backup and temporary names are not matched by Backup")
+	}
+	// A file name with no directory is written in the current
+	// directory, not the temporary directory, which here does not
+	// exist.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("TMPDIR", filepath.Join(dir, "missing"))
+	err = WriteFileAtomic("baseline.json", []byte("{}\n"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read(filepath.Join(dir, "baseline.json")) != "{}\n" {
+		t.Errorf("Wrong file written with a relative name")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n17\n"
+	var diff strings.Builder
+	err := UnifiedDiff(&diff, "a", "b", []byte(a), []byte(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -13,4 +13,5 @@
+ 13
+ 14
+ 15
+-16
+\ No newline at end of file
++16
++17
+`
+	if diff.String() != expect {
+		t.Errorf("Wrong diff:\n%s", diff.String())
+	}
+	diff.Reset()
+	UnifiedDiff(&diff, "a", "b", []byte(a), []byte(a))
+	if diff.Len() != 0 {
+		t.Errorf("Diff of the same files:\n%s", diff.String())
+	}
+}
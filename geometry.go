@@ -0,0 +1,414 @@
+package kvg
+
+import "math"
+
+// Point is a point, or a direction, in the coordinates of the SVG.
+type Point struct {
+	X, Y float64
+}
+
+func (p Point) add(q Point) Point {
+	return Point{p.X + q.X, p.Y + q.Y}
+}
+
+func (p Point) sub(q Point) Point {
+	return Point{p.X - q.X, p.Y - q.Y}
+}
+
+func (p Point) scale(s float64) Point {
+	return Point{p.X * s, p.Y * s}
+}
+
+// Len returns the distance of p from the origin.
+func (p Point) Len() float64 {
+	return math.Hypot(p.X, p.Y)
+}
+
+// unit returns p scaled to length one, or p itself if it is zero.
+func (p Point) unit() Point {
+	l := p.Len()
+	if l == 0 {
+		return p
+	}
+	return p.scale(1 / l)
+}
+
+// Rect is a rectangle with its sides parallel to the axes. A Rect
+// containing nothing has Min greater than Max, like EmptyRect.
+type Rect struct {
+	Min, Max Point
+}
+
+// EmptyRect is the rectangle containing nothing, which gives the other
+// rectangle when united with it.
+var EmptyRect = Rect{
+	Min: Point{math.Inf(1), math.Inf(1)},
+	Max: Point{math.Inf(-1), math.Inf(-1)},
+}
+
+// Empty returns true if r contains nothing.
+func (r Rect) Empty() bool {
+	return r.Min.X > r.Max.X || r.Min.Y > r.Max.Y
+}
+
+// Union returns the smallest rectangle containing both r and o.
+func (r Rect) Union(o Rect) Rect {
+	return Rect{
+		Min: Point{math.Min(r.Min.X, o.Min.X), math.Min(r.Min.Y, o.Min.Y)},
+		Max: Point{math.Max(r.Max.X, o.Max.X), math.Max(r.Max.Y, o.Max.Y)},
+	}
+}
+
+// include returns the smallest rectangle containing r and p.
+func (r Rect) include(p Point) Rect {
+	return r.Union(Rect{p, p})
+}
+
+// Width returns the width of r, or zero if it is empty.
+func (r Rect) Width() float64 {
+	return math.Max(r.Max.X-r.Min.X, 0)
+}
+
+// Height returns the height of r, or zero if it is empty.
+func (r Rect) Height() float64 {
+	return math.Max(r.Max.Y-r.Min.Y, 0)
+}
+
+// segment is a line, a quadratic Bézier or a cubic Bézier, with two,
+// three or four points.
+type segment []Point
+
+// at returns the point at t, from zero to one, along s.
+func (s segment) at(t float64) Point {
+	u := 1 - t
+	switch len(s) {
+	case 2:
+		return s[0].scale(u).add(s[1].scale(t))
+	case 3:
+		return s[0].scale(u * u).add(s[1].scale(2 * u * t)).add(s[2].scale(t * t))
+	}
+	return s[0].scale(u * u * u).add(s[1].scale(3 * u * u * t)).
+		add(s[2].scale(3 * u * t * t)).add(s[3].scale(t * t * t))
+}
+
+// deriv returns the derivative of s at t.
+func (s segment) deriv(t float64) Point {
+	u := 1 - t
+	switch len(s) {
+	case 2:
+		return s[1].sub(s[0])
+	case 3:
+		return s[1].sub(s[0]).scale(2 * u).add(s[2].sub(s[1]).scale(2 * t))
+	}
+	return s[1].sub(s[0]).scale(3 * u * u).add(s[2].sub(s[1]).scale(6 * u * t)).
+		add(s[3].sub(s[2]).scale(3 * t * t))
+}
+
+// startTangent returns the direction in which s starts. If the first
+// control points are on the start point, this is the direction to the
+// first control point which is not.
+func (s segment) startTangent() Point {
+	for _, p := range s[1:] {
+		if p != s[0] {
+			return p.sub(s[0]).unit()
+		}
+	}
+	return Point{}
+}
+
+// endTangent returns the direction in which s ends.
+func (s segment) endTangent() Point {
+	end := s[len(s)-1]
+	for i := len(s) - 2; i >= 0; i-- {
+		if s[i] != end {
+			return end.sub(s[i]).unit()
+		}
+	}
+	return Point{}
+}
+
+// bounds returns the exact bounding box of s, from its end points and
+// the points where its derivative is zero in x or y.
+func (s segment) bounds() Rect {
+	r := EmptyRect.include(s[0]).include(s[len(s)-1])
+	var ts []float64
+	coords := func(p Point, x bool) float64 {
+		if x {
+			return p.X
+		}
+		return p.Y
+	}
+	for _, x := range []bool{true, false} {
+		switch len(s) {
+		case 3:
+			p0, p1, p2 := coords(s[0], x), coords(s[1], x), coords(s[2], x)
+			if d := p0 - 2*p1 + p2; d != 0 {
+				ts = append(ts, (p0-p1)/d)
+			}
+		case 4:
+			p0, p1, p2, p3 := coords(s[0], x), coords(s[1], x), coords(s[2], x), coords(s[3], x)
+			ts = append(ts, quadraticRoots(-p0+3*p1-3*p2+p3, 2*(p0-2*p1+p2), p1-p0)...)
+		}
+	}
+	for _, t := range ts {
+		if t > 0 && t < 1 {
+			r = r.include(s.at(t))
+		}
+	}
+	return r
+}
+
+// quadraticRoots returns the real roots of at² + bt + c.
+func quadraticRoots(a, b, c float64) []float64 {
+	if math.Abs(a) < 1e-12 {
+		if b == 0 {
+			return nil
+		}
+		return []float64{-c / b}
+	}
+	disc := b*b - 4*a*c
+	if disc < 0 {
+		return nil
+	}
+	sq := math.Sqrt(disc)
+	return []float64{(-b + sq) / (2 * a), (-b - sq) / (2 * a)}
+}
+
+// The nodes and weights of five-point Gauss-Legendre quadrature.
+var gaussNodes = [5]float64{0, -0.5384693101056831, 0.5384693101056831, -0.9061798459386640, 0.9061798459386640}
+var gaussWeights = [5]float64{0.5688888888888889, 0.4786286704993665, 0.4786286704993665, 0.2369268850561891, 0.2369268850561891}
+
+// gauss estimates the length of s between a and b.
+func (s segment) gauss(a, b float64) (l float64) {
+	h := (b - a) / 2
+	m := (a + b) / 2
+	for i, x := range gaussNodes {
+		l += gaussWeights[i] * s.deriv(m+h*x).Len()
+	}
+	return l * h
+}
+
+// length returns the length of s between a and b.
+func (s segment) length(a, b float64) float64 {
+	if len(s) == 2 {
+		return s[1].sub(s[0]).Len() * (b - a)
+	}
+	return s.integrate(a, b, s.gauss(a, b), 0)
+}
+
+// integrate refines the estimate whole of the length of s between a
+// and b, by halving the interval until the halves agree with it.
+func (s segment) integrate(a, b, whole float64, depth int) float64 {
+	m := (a + b) / 2
+	l, r := s.gauss(a, m), s.gauss(m, b)
+	if depth >= 16 || math.Abs(l+r-whole) < 1e-10 {
+		return l + r
+	}
+	return s.integrate(a, m, l, depth+1) + s.integrate(m, b, r, depth+1)
+}
+
+// atLength returns the parameter of s where the length from its start
+// is l, which must be between zero and the length of s.
+func (s segment) atLength(l float64) float64 {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 50; i++ {
+		m := (lo + hi) / 2
+		if s.length(0, m) < l {
+			lo = m
+		} else {
+			hi = m
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// segments returns the pieces of the normalized path. Closing a
+// subpath gives a line back to its start, if it is not there already.
+func (p SVGPath) segments() (segs []segment) {
+	var cur, start Point
+	for _, subpath := range p.Normalize().Subpaths {
+		for _, command := range subpath.Commands {
+			a := command.Params
+			switch command.Symbol {
+			case "M":
+				cur = Point{a[0], a[1]}
+				start = cur
+				continue
+			case "Z":
+				if cur != start {
+					segs = append(segs, segment{cur, start})
+				}
+				cur = start
+				continue
+			}
+			s := segment{cur}
+			for i := 0; i < len(a); i += 2 {
+				s = append(s, Point{a[i], a[i+1]})
+			}
+			segs = append(segs, s)
+			cur = s[len(s)-1]
+		}
+	}
+	return segs
+}
+
+// Bounds returns the exact bounding box of the path, or EmptyRect if it
+// has no points. This does not include the width of the line.
+func (p SVGPath) Bounds() (r Rect) {
+	r = EmptyRect
+	for _, subpath := range p.Normalize().Subpaths {
+		for _, command := range subpath.Commands {
+			if command.Symbol == "M" {
+				r = r.include(Point{command.Params[0], command.Params[1]})
+			}
+		}
+	}
+	for _, s := range p.segments() {
+		r = r.Union(s.bounds())
+	}
+	return r
+}
+
+// Length returns the length of the path.
+func (p SVGPath) Length() (l float64) {
+	for _, s := range p.segments() {
+		l += s.length(0, 1)
+	}
+	return l
+}
+
+// Start returns the point where the path starts.
+func (p SVGPath) Start() Point {
+	n := p.Normalize()
+	if len(n.Subpaths) == 0 || len(n.Subpaths[0].Commands) == 0 {
+		return Point{}
+	}
+	a := n.Subpaths[0].Commands[0].Params
+	return Point{a[0], a[1]}
+}
+
+// End returns the point where the path ends.
+func (p SVGPath) End() Point {
+	segs := p.segments()
+	if len(segs) == 0 {
+		return p.Start()
+	}
+	last := segs[len(segs)-1]
+	return last[len(last)-1]
+}
+
+// StartTangent returns the direction in which the path starts, as a
+// vector of length one, or zero if the path does not go anywhere.
+func (p SVGPath) StartTangent() Point {
+	for _, s := range p.segments() {
+		if t := s.startTangent(); t != (Point{}) {
+			return t
+		}
+	}
+	return Point{}
+}
+
+// EndTangent returns the direction in which the path ends, as a vector
+// of length one, or zero if the path does not go anywhere.
+func (p SVGPath) EndTangent() Point {
+	segs := p.segments()
+	for i := len(segs) - 1; i >= 0; i-- {
+		if t := segs[i].endTangent(); t != (Point{}) {
+			return t
+		}
+	}
+	return Point{}
+}
+
+// PointAt returns the point at the fraction f of the length of the
+// path, so zero gives the start and one gives the end.
+func (p SVGPath) PointAt(f float64) Point {
+	segs := p.segments()
+	if len(segs) == 0 {
+		return p.Start()
+	}
+	lengths := make([]float64, len(segs))
+	total := 0.0
+	for i, s := range segs {
+		lengths[i] = s.length(0, 1)
+		total += lengths[i]
+	}
+	l := math.Max(0, math.Min(f, 1)) * total
+	for i, s := range segs {
+		if l <= lengths[i] || i == len(segs)-1 {
+			if lengths[i] == 0 {
+				return s[0]
+			}
+			return s.at(s.atLength(math.Min(l, lengths[i])))
+		}
+		l -= lengths[i]
+	}
+	return Point{}
+}
+
+// SVGPath parses the 'd' attribute of p.
+func (p *Path) SVGPath() (SVGPath, error) {
+	return PathParser(p.D)
+}
+
+// Bounds returns the bounding box of the stroke p. The error is from
+// parsing its 'd' attribute.
+func (p *Path) Bounds() (r Rect, err error) {
+	path, err := p.SVGPath()
+	if err != nil {
+		return EmptyRect, err
+	}
+	return path.Bounds(), nil
+}
+
+// Length returns the length of the stroke p. The error is from parsing
+// its 'd' attribute.
+func (p *Path) Length() (l float64, err error) {
+	path, err := p.SVGPath()
+	if err != nil {
+		return 0, err
+	}
+	return path.Length(), nil
+}
+
+// Bounds returns the bounding box of all the strokes in g, or EmptyRect
+// if it has none. The error is from parsing the 'd' attribute of a
+// stroke, which is a PathError.
+func (g *Group) Bounds() (r Rect, err error) {
+	r = EmptyRect
+	for _, p := range g.GetPaths() {
+		b, err := p.Bounds()
+		if err != nil {
+			return EmptyRect, &PathError{ID: p.ID, Err: err}
+		}
+		r = r.Union(b)
+	}
+	return r, nil
+}
+
+// Length returns the total length of all the strokes in g. The error
+// is as for Bounds.
+func (g *Group) Length() (l float64, err error) {
+	for _, p := range g.GetPaths() {
+		pl, err := p.Length()
+		if err != nil {
+			return 0, &PathError{ID: p.ID, Err: err}
+		}
+		l += pl
+	}
+	return l, nil
+}
+
+// PathError is an error in the 'd' attribute of the path with the id ID.
+type PathError struct {
+	ID  string
+	Err error
+}
+
+func (e *PathError) Error() string {
+	return e.ID + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
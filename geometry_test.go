@@ -0,0 +1,185 @@
+package kvg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func near(a, b Point) bool {
+	return math.Abs(a.X-b.X) < 0.01 && math.Abs(a.Y-b.Y) < 0.01
+}
+
+func TestGeometry(t *testing.T) {
+	tests := []struct {
+		d                string
+		length           float64
+		bounds           Rect
+		start, end       Point
+		startTan, endTan Point
+		middle           Point
+	}{
+		{"M0,0l3,4", 5, Rect{Point{0, 0}, Point{3, 4}},
+			Point{0, 0}, Point{3, 4}, Point{0.6, 0.8}, Point{0.6, 0.8}, Point{1.5, 2}},
+		// The length of the curve, from a polyline of two million
+		// pieces, is 20 to eleven decimal places.
+		{"M0,0C0,10,10,10,10,0", 20, Rect{Point{0, 0}, Point{10, 7.5}},
+			Point{0, 0}, Point{10, 0}, Point{0, 1}, Point{0, -1}, Point{5, 7.5}},
+		{"M0,0A10,10,0,0,1,20,0", 10 * math.Pi, Rect{Point{0, -10}, Point{20, 0}},
+			Point{0, 0}, Point{20, 0}, Point{0, -1}, Point{0, 1}, Point{10, -10}},
+		{"M1,1h4v3H1z", 14, Rect{Point{1, 1}, Point{5, 4}},
+			Point{1, 1}, Point{1, 1}, Point{1, 0}, Point{0, -1}, Point{5, 4}},
+	}
+	for _, test := range tests {
+		path, err := PathParser(test.d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := path.Length()
+		if math.Abs(l-test.length) > 0.01 {
+			t.Errorf("%s: length %g, expected %g", test.d, l, test.length)
+		}
+		b := path.Bounds()
+		if !near(b.Min, test.bounds.Min) || !near(b.Max, test.bounds.Max) {
+			t.Errorf("%s: bounds %v, expected %v", test.d, b, test.bounds)
+		}
+		got := []Point{path.Start(), path.End(), path.StartTangent(), path.EndTangent(), path.PointAt(0.5)}
+		expect := []Point{test.start, test.end, test.startTan, test.endTan, test.middle}
+		for i := range got {
+			if !near(got[i], expect[i]) {
+				t.Errorf("%s: point %d is %v, expected %v", test.d, i, got[i], expect[i])
+			}
+		}
+	}
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := svg.BaseGroup().Bounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Min.X < 0 || b.Min.Y < 0 || b.Max.X > 109 || b.Max.Y > 109 || b.Width() < 60 {
+		t.Errorf("Bad bounds of kanji %v", b)
+	}
+	svg.GetPaths()[3].D = "M1,2,"
+	_, err = svg.BaseGroup().Length()
+	var perr *PathError
+	if !errors.As(err, &perr) || perr.ID != "kvg:08475-s4" {
+		t.Errorf("Expected PathError, got %v", err)
+	}
+}
+
+func TestResample(t *testing.T) {
+	path, err := PathParser("M0,0L10,0L10,10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := path.Resample(5)
+	expect := []Point{{0, 0}, {5, 0}, {10, 0}, {10, 5}, {10, 10}}
+	if len(got) != len(expect) {
+		t.Fatalf("Expected %v, got %v", expect, got)
+	}
+	for i := range got {
+		if !near(got[i], expect[i]) {
+			t.Errorf("Point %d: expected %v, got %v", i, expect[i], got[i])
+		}
+	}
+	// The gap between the subpaths is not part of the distance.
+	path, err = PathParser("M0,0L10,0M0,10L10,10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = path.Resample(5)
+	expect = []Point{{0, 0}, {5, 0}, {10, 0}, {5, 10}, {10, 10}}
+	if path.Length() != 20 || len(got) != len(expect) {
+		t.Fatalf("Expected %v, got %v, length %g", expect, got, path.Length())
+	}
+	for i := range got {
+		if !near(got[i], expect[i]) {
+			t.Errorf("Subpaths point %d: expected %v, got %v", i, expect[i], got[i])
+		}
+	}
+	path, err = PathParser("M0,0A10,10,0,0,1,20,0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := path.Flatten(0.05)
+	if len(line) < 5 || line[0] != (Point{0, 0}) || !near(line[len(line)-1], Point{20, 0}) {
+		t.Errorf("Bad flattened arc %v", line)
+	}
+	for i := 1; i < len(line); i++ {
+		// The middle of each line is inside the circle by no more
+		// than the tolerance.
+		mid := line[i].add(line[i-1]).scale(0.5)
+		if d := 10 - mid.sub(Point{10, 0}).Len(); d < -0.01 || d > 0.06 {
+			t.Errorf("Line %d is %g from the arc", i, d)
+		}
+	}
+}
+
+func TestExportStrokes(t *testing.T) {
+	opts := ExportOptions{
+		Points: 8,
+		Filter: func(kf *KanjiFile) bool {
+			return kf.Name == "08475-Kaisho.svg"
+		},
+	}
+	var buf bytes.Buffer
+	err := NewCorpusFS(testFS()).ExportStrokes(context.Background(), &buf, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 12 {
+		t.Fatalf("Expected 12 strokes, got %d", len(lines))
+	}
+	var r StrokeRecord
+	err = json.Unmarshal([]byte(lines[1]), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Kanji != '葵' || r.Variant != "Kaisho" || r.Stroke != 2 || r.Type != "㇑a" ||
+		strings.Join(r.Elements, "") != "葵艹" || len(r.Points) != 8 {
+		t.Errorf("Bad record %+v", r)
+	}
+	opts.Format = ExportBinary
+	buf.Reset()
+	err = NewCorpusFS(testFS()).ExportStrokes(context.Background(), &buf, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	err = ReadStrokes(&buf, func(b *StrokeRecord) error {
+		n++
+		if n != 2 {
+			return nil
+		}
+		if b.Kanji != r.Kanji || b.Variant != r.Variant || b.Type != r.Type ||
+			strings.Join(b.Elements, "") != "葵艹" || len(b.Points) != 8 ||
+			math.Abs(b.Points[7][1]-r.Points[7][1]) > 1e-4 {
+			t.Errorf("Binary record %+v differs from %+v", b, r)
+		}
+		return nil
+	})
+	if err != nil || n != 12 {
+		t.Errorf("Read %d binary records, error %v", n, err)
+	}
+	// An element too long for its length byte is an error, rather than
+	// being cut, perhaps in the middle of a character.
+	buf.Reset()
+	sw := NewStrokeWriter(&buf, ExportBinary)
+	long := r
+	long.Elements = []string{"葵", strings.Repeat("艹", 100)}
+	if err = sw.Write(&long); err == nil {
+		t.Errorf("No error for an element of %d bytes", len(long.Elements[1]))
+	}
+	long.Elements = []string{"葵", strings.Repeat("艹", 85)}
+	if err = sw.Write(&long); err != nil {
+		t.Errorf("Error for an element of %d bytes: %v", len(long.Elements[1]), err)
+	}
+}
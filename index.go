@@ -0,0 +1,245 @@
+package kvg
+
+import (
+	"context"
+	"strings"
+)
+
+// One file of a corpus in an Index.
+type IndexEntry struct {
+	// The name of the file within the corpus, such as "08475.svg".
+	Name string
+	// The kanji which the file is for.
+	Kanji rune
+	// The variant part of the file name, such as "Kaisho", or the
+	// empty string.
+	Variant string
+	// The parsed file.
+	SVG *SVG
+	// The number of strokes, in other words paths.
+	Strokes int
+}
+
+// The key for the radical map.
+type radicalKey struct {
+	kind, element string
+}
+
+// An index of a corpus, built once from the parsed files, for
+// answering questions like which kanji contain a particular element,
+// without reading all the files again. Make one with Corpus.Index, or
+// with NewIndex and Add.
+type Index struct {
+	// All the entries, in the order they were added.
+	Entries []*IndexEntry
+	// Map from file name to entry.
+	names map[string]*IndexEntry
+	// Map from element to position to entries.
+	elements map[string]map[string][]*IndexEntry
+	radicals map[radicalKey][]*IndexEntry
+	strokes  map[int][]*IndexEntry
+	types    map[string][]*IndexEntry
+}
+
+// Make an empty index.
+func NewIndex() *Index {
+	return &Index{
+		names:    make(map[string]*IndexEntry),
+		elements: make(map[string]map[string][]*IndexEntry),
+		radicals: make(map[radicalKey][]*IndexEntry),
+		strokes:  make(map[int][]*IndexEntry),
+		types:    make(map[string][]*IndexEntry),
+	}
+}
+
+// Read all the files of the corpus and make an index of them. The
+// options are passed to Walk. If some files could not be read, the
+// index of the other files is returned along with the FileErrors.
+func (c *Corpus) Index(ctx context.Context, opts *WalkOptions) (ix *Index, err error) {
+	ix = NewIndex()
+	o := WalkOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Ordered = true
+	err = c.Walk(ctx, &o, func(kf *KanjiFile) error {
+		ix.Add(kf.Name, &kf.SVG)
+		return nil
+	})
+	return ix, err
+}
+
+// The stroke types under which a path of type t is indexed. Some
+// paths have alternatives such as "㇔/㇀", and some have suffixes such
+// as "㇑a", so as well as the type itself, each alternative and the
+// stroke without its suffix are included.
+func strokeTypeKeys(t string) (keys []string) {
+	seen := make(map[string]bool)
+	add := func(k string) {
+		if len(k) == 0 || seen[k] {
+			return
+		}
+		seen[k] = true
+		keys = append(keys, k)
+	}
+	add(t)
+	for _, alt := range strings.Split(t, "/") {
+		add(alt)
+		r := []rune(alt)
+		if len(r) > 0 {
+			add(string(r[0]))
+		}
+	}
+	return keys
+}
+
+// Add the file called name, with its parsed contents svg, to the
+// index. The index keeps svg, so it should not be altered
+// afterwards. If svg has no base group, it is indexed only by name.
+func (ix *Index) Add(name string, svg *SVG) {
+	_, num, variant := FileToParts(name)
+	e := &IndexEntry{
+		Name:    name,
+		Kanji:   rune(num),
+		Variant: variant,
+		SVG:     svg,
+	}
+	ix.Entries = append(ix.Entries, e)
+	ix.names[name] = e
+	if !svg.HasBaseGroup() {
+		return
+	}
+	base := svg.BaseGroup()
+	paths := base.GetPaths()
+	e.Strokes = len(paths)
+	ix.strokes[e.Strokes] = append(ix.strokes[e.Strokes], e)
+	// Each of the following maps keeps track of what has been added
+	// for this entry, so that it is only added once for each key.
+	added := make(map[string]bool)
+	addElement := func(element, position string) {
+		k := element + "\x00" + position
+		if len(element) == 0 || added[k] {
+			return
+		}
+		added[k] = true
+		if ix.elements[element] == nil {
+			ix.elements[element] = make(map[string][]*IndexEntry)
+		}
+		ix.elements[element][position] = append(ix.elements[element][position], e)
+	}
+	for _, g := range base.GetGroups() {
+		if g == base {
+			continue
+		}
+		addElement(g.Element, g.Position)
+		addElement(g.Original, g.Position)
+	}
+	var rad Radical
+	base.SearchRadical(&rad)
+	addedRad := make(map[radicalKey]bool)
+	addRadical := func(kind string, groups []*Group) {
+		for _, g := range groups {
+			for _, el := range []string{g.Element, g.Original} {
+				k := radicalKey{kind, el}
+				if len(el) == 0 || addedRad[k] {
+					continue
+				}
+				addedRad[k] = true
+				ix.radicals[k] = append(ix.radicals[k], e)
+			}
+		}
+	}
+	addRadical("general", rad.General)
+	addRadical("nelson", rad.Nelson)
+	addRadical("tradit", rad.Tradit)
+	addRadical("jis", rad.JIS)
+	addedType := make(map[string]bool)
+	for _, p := range paths {
+		for _, k := range strokeTypeKeys(p.Type) {
+			if addedType[k] {
+				continue
+			}
+			addedType[k] = true
+			ix.types[k] = append(ix.types[k], e)
+		}
+	}
+}
+
+// The number of files in the index.
+func (ix *Index) Len() int {
+	return len(ix.Entries)
+}
+
+// The entry for the file called name, or nil if there is none.
+func (ix *Index) File(name string) *IndexEntry {
+	return ix.names[name]
+}
+
+// The entry for kanji with the variant, such as "Kaisho", or the
+// empty string for the usual form, or nil if there is none.
+func (ix *Index) Get(kanji rune, variant string) *IndexEntry {
+	return ix.names[FileName(kanji, variant)]
+}
+
+// Merge several lists of entries, keeping the order in which they
+// were added to the index and removing duplicates.
+func (ix *Index) merge(lists ...[]*IndexEntry) (entries []*IndexEntry) {
+	if len(lists) == 1 {
+		return lists[0]
+	}
+	want := make(map[*IndexEntry]bool)
+	for _, l := range lists {
+		for _, e := range l {
+			want[e] = true
+		}
+	}
+	for _, e := range ix.Entries {
+		if want[e] {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// The files containing a group with element, either as kvg:element or
+// kvg:original, at position, such as "left". If position is the empty
+// string, the files with element at any position, or at no position,
+// are returned. The base element of each file is not included, so
+// for example 葵 is not returned for element 葵.
+func (ix *Index) WithElement(element, position string) []*IndexEntry {
+	positions := ix.elements[element]
+	if len(position) > 0 {
+		return positions[position]
+	}
+	var lists [][]*IndexEntry
+	for _, l := range positions {
+		lists = append(lists, l)
+	}
+	return ix.merge(lists...)
+}
+
+// The files with element as a radical of the given kind, which is
+// one of the values of kvg:radical, "general", "nelson", "tradit" or
+// "jis". If kind is the empty string, radicals of all kinds are
+// included.
+func (ix *Index) WithRadical(kind, element string) []*IndexEntry {
+	if len(kind) > 0 {
+		return ix.radicals[radicalKey{kind, element}]
+	}
+	var lists [][]*IndexEntry
+	for _, kind := range []string{"general", "nelson", "tradit", "jis"} {
+		lists = append(lists, ix.radicals[radicalKey{kind, element}])
+	}
+	return ix.merge(lists...)
+}
+
+// The files with n strokes.
+func (ix *Index) WithStrokes(n int) []*IndexEntry {
+	return ix.strokes[n]
+}
+
+// The files with at least one stroke of kvg:type t. A type such as
+// "㇑" also matches strokes of type "㇑a" or "㇑/㇒".
+func (ix *Index) WithStrokeType(t string) []*IndexEntry {
+	return ix.types[t]
+}
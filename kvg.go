@@ -2,10 +2,11 @@
 package kvg
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -17,66 +18,194 @@ import (
 // This matches most of the variant endings.
 var Variant = regexp.MustCompile(`-(Kaisho|MidFst|HzLst|VtLst|HzFstLeRi|HzFstRiLe|TenLst|Hyougai|Jinmei|HzFst|VtFstRiLe|LeFst|Vt6|VtFstRiLe|HzFst|HzFstVtLst|MdLst|VtFst|Vt4|Ten3|DgLst|Insatsu|MdFst|MdFst2|Dg3|TenFst|RiLe|NoDot)`)
 
-// The base directory
+// The base directory. This is used by TFile, ExamineAllFiles and
+// ExamineAllFilesSimple. To work with other directories, or more than
+// one directory at once, use a Corpus.
 var KVDir = "/home/ben/software/kanjivg/kanji"
 
+// The namespace of the KanjiVG attributes such as kvg:element. The
+// KanjiVG files use the prefix "kvg" for it without declaring it
+// anywhere except in the DOCTYPE, so attributes with that prefix are
+// treated as being in this namespace unless the file binds "kvg" to
+// something else.
+const KVGNamespace = "http://kanjivg.tagaini.net"
+
+// The namespace of the SVG elements.
+const SVGNamespace = "http://www.w3.org/2000/svg"
+
+// Errors returned by the library. These are usually wrapped with more
+// information, so test for them using errors.Is.
+var (
+	// The base name given to SetBase is not of the form "kvg:...".
+	ErrBadBase = errors.New("base name does not start with 'kvg:'")
+	// A file name is not of the form used by KanjiVG, for example
+	// "08475.svg" or "08475-Kaisho.svg".
+	ErrBadFileName = errors.New("not a KanjiVG file name")
+	// An id attribute is not of the form used by KanjiVG.
+	ErrBadID = errors.New("not a KanjiVG id")
+	// The SVG could not be converted into XML.
+	ErrMarshal = errors.New("error marshalling")
+	// The SVG does not have the StrokePaths group containing the base
+	// group, so it cannot be renumbered or written.
+	ErrNoBaseGroup = errors.New("no base group")
+)
+
 // A path, in other words a stroke of the kanji.
 type Path struct {
 	XMLName xml.Name `xml:"path"`
 	ID      string   `xml:"id,attr"`
-	Type    string   `xml:"kvg:type,attr,omitempty"`
+	Type    string   `xml:"http://kanjivg.tagaini.net type,attr,omitempty"`
 	D       string   `xml:"d,attr"`
 	Parent  *Child   `xml:"-"`
 	Class   string   `xml:"class,attr,omitempty"`
+	// Attributes which this library does not know about.
+	Extra  []xml.Attr `xml:",any,attr"`
+	Format Format     `xml:"-"`
 }
 
 // Text holder, this contains the stroke numbers.
 type Text struct {
-	XMLName   xml.Name `xml:"text"`
-	Transform string   `xml:"transform,attr,omitempty"`
-	Content   []byte   `xml:",chardata"`
-	Parent    *Child   `xml:"-"`
-	Class     string   `xml:"class,attr,omitempty"`
+	XMLName   xml.Name   `xml:"text"`
+	Transform string     `xml:"transform,attr,omitempty"`
+	Content   []byte     `xml:",chardata"`
+	Parent    *Child     `xml:"-"`
+	Class     string     `xml:"class,attr,omitempty"`
+	Extra     []xml.Attr `xml:",any,attr"`
+	Format    Format     `xml:"-"`
+}
+
+// Something in a group which is not a group, path or text element,
+// such as a comment or an element this library does not know
+// about. It is kept exactly as it was in the file.
+type Other struct {
+	Raw string
 }
 
-// Either a group or a path element.
+// Either a group, a path, or a text element, or something else.
 type Child struct {
 	Path    Path
 	Group   Group
 	Text    Text
+	Other   Other
 	IsGroup bool
 	IsText  bool
+	IsOther bool
 	Parent  *Group `xml:"-"`
 }
 
-// A group.
+// A group. The attributes with the kvg prefix are in KVGNamespace. If
+// a group is encoded with xml.Marshal, the encoder declares a prefix
+// for that namespace. To get the "kvg:" prefix used by the KanjiVG
+// files, use MakeXML.
 type Group struct {
-	XMLName     xml.Name `xml:"g"`
-	ID          string   `xml:"id,attr,omitempty"`
-	Element     string   `xml:"kvg:element,attr,omitempty"`
-	Part        string   `xml:"kvg:part,attr,omitempty"`
-	Variant     bool     `xml:"kvg:variant,attr,omitempty"`
-	Number      string   `xml:"kvg:number,attr,omitempty"`
-	Original    string   `xml:"kvg:original,attr,omitempty"`
-	Partial     bool     `xml:"kvg:partial,attr,omitempty"`
-	TradForm    string   `xml:"kvg:tradForm,attr,omitempty"`
-	Position    string   `xml:"kvg:position,attr,omitempty"`
-	Radical     string   `xml:"kvg:radical,attr,omitempty"`
-	Phon        string   `xml:"kvg:phon,attr,omitempty"`
-	RadicalForm string   `xml:"kvg:radicalForm,attr,omitempty"`
-	Style       string   `xml:"style,attr,omitempty"`
+	XMLName     xml.Name   `xml:"g"`
+	ID          string     `xml:"id,attr,omitempty"`
+	Element     string     `xml:"http://kanjivg.tagaini.net element,attr,omitempty"`
+	Part        string     `xml:"http://kanjivg.tagaini.net part,attr,omitempty"`
+	Variant     bool       `xml:"http://kanjivg.tagaini.net variant,attr,omitempty"`
+	Number      string     `xml:"http://kanjivg.tagaini.net number,attr,omitempty"`
+	Original    string     `xml:"http://kanjivg.tagaini.net original,attr,omitempty"`
+	Partial     bool       `xml:"http://kanjivg.tagaini.net partial,attr,omitempty"`
+	TradForm    string     `xml:"http://kanjivg.tagaini.net tradForm,attr,omitempty"`
+	Position    string     `xml:"http://kanjivg.tagaini.net position,attr,omitempty"`
+	Radical     string     `xml:"http://kanjivg.tagaini.net radical,attr,omitempty"`
+	Phon        string     `xml:"http://kanjivg.tagaini.net phon,attr,omitempty"`
+	RadicalForm string     `xml:"http://kanjivg.tagaini.net radicalForm,attr,omitempty"`
+	Style       string     `xml:"style,attr,omitempty"`
+	Extra       []xml.Attr `xml:",any,attr"`
 	Children    []Child
 	Parent      *Child `xml:"-"`
+	Format      Format `xml:"-"`
 }
 
 // An entire file.
 type SVG struct {
-	XMLName xml.Name `xml:"svg"`
-	XMLNS   string   `xml:"xmlns,attr"`
-	Width   string   `xml:"width,attr"`
-	Height  string   `xml:"height,attr"`
-	ViewBox string   `xml:"viewBox,attr,omitempty"`
-	Groups  []Group  `xml:"g"`
+	XMLName xml.Name   `xml:"svg"`
+	XMLNS   string     `xml:"xmlns,attr"`
+	Width   string     `xml:"width,attr"`
+	Height  string     `xml:"height,attr"`
+	ViewBox string     `xml:"viewBox,attr,omitempty"`
+	Extra   []xml.Attr `xml:",any,attr"`
+	Groups  []Group    `xml:"g"`
+	// Everything in the file before the svg element, such as the
+	// copyright comment and the DOCTYPE, and everything after it.
+	Prolog string `xml:"-"`
+	Epilog string `xml:"-"`
+	// Anything other than a group within the svg element, such as
+	// comments, is kept in the Space of the Format.
+	Format Format `xml:"-"`
+}
+
+// How an element was written in the file it was read from, so that
+// if it is not altered, it can be written back out exactly the same
+// way. This is filled in by ParseKanji. Elements which do not have
+// it, for example ones which have been made by the program, are
+// written in the style of the KanjiVG files.
+type Format struct {
+	// The start tag as it was in the file, such as `<g id="kvg:08475"
+	// kvg:element="葵">`. If this is the empty string, nothing else
+	// is recorded.
+	Tag string
+	// The attributes of the start tag, in order. Tag is only used if
+	// the element's attributes are still these. Otherwise a new start
+	// tag is made, with any of these attributes which are left in the
+	// same order as before.
+	Attrs []xml.Attr
+	// The text between the children, such as the newlines and tabs,
+	// with one more entry than the number of children. This is only
+	// used if the number of children is still the same, otherwise
+	// the children are indented in the KanjiVG style.
+	Space []string
+	// The end tag, such as "</g>", or the empty string if the element
+	// was written like <path .../>.
+	End string
+	// Where the element was in the file.
+	Span SourceSpan
+}
+
+// Where an element was in the file it was read from, from the start of
+// its start tag to the end of its end tag, or of its start tag if it
+// was written like <path .../>. The lines and columns count from one,
+// and the columns count characters rather than bytes. For a kanji read
+// by CombinedReader, these are within the combined file.
+type SourceSpan struct {
+	// The byte offsets of the start and the end.
+	Offset, End int64
+	// The line and column of the start.
+	Line, Column int
+	// The line and column of the end, which is just after the last
+	// character of the element.
+	EndLine, EndColumn int
+}
+
+// Is s known? It is not known for elements which were not read from a
+// file, or read with xml.Unmarshal, or whose format has been cleared.
+func (s SourceSpan) IsValid() bool {
+	return s.Line > 0
+}
+
+func (s SourceSpan) String() string {
+	return fmt.Sprintf("%d:%d-%d:%d", s.Line, s.Column, s.EndLine, s.EndColumn)
+}
+
+// Where g was in the file it was read from.
+func (g *Group) Source() SourceSpan {
+	return g.Format.Span
+}
+
+// Where p was in the file it was read from.
+func (p *Path) Source() SourceSpan {
+	return p.Format.Span
+}
+
+// Where t was in the file it was read from.
+func (t *Text) Source() SourceSpan {
+	return t.Format.Span
+}
+
+// Where the svg element was in the file it was read from.
+func (svg *SVG) Source() SourceSpan {
+	return svg.Format.Span
 }
 
 // This is the heading as repeated in each file.
@@ -133,50 +262,49 @@ func ExpectRadical(k rune) bool {
 	return true
 }
 
-// Change the formatting of xmlout to that used by KanjiVG project,
-// and add the common heading material. The KanjiVG files are indented
-// using tabs, but with some of the indentation inconsistent.
-func fixXML(xmlout []byte) []byte {
-	outFixed := strings.Replace(string(xmlout), "></path>", "/>", -1)
-	outFixed = strings.Replace(outFixed, "\t<g", "<g", -1)
-	outFixed = strings.Replace(outFixed, "\t<g", "<g", -1)
-	outFixed = strings.Replace(outFixed, "\t\t<path", "<path", -1)
-	outFixed = strings.Replace(outFixed, "\t<text", "<text", -1)
-	outFixed = strings.Replace(outFixed, "\t</g>", "</g>", -1)
-	outFixed = strings.Replace(outFixed, "\t</g>", "</g>", -1)
-	outstring := Heading + outFixed + "\n"
-	return []byte(outstring)
-}
-
 // Make kanjivg into the XML of the KanjiVG files.
-func (kanjivg *SVG) MakeXML() (output []byte) {
+func (kanjivg *SVG) MakeXML() (output []byte, err error) {
 	return MakeXML(kanjivg)
 }
 
-// Make kanjivg into the XML of the KanjiVG files.
-func MakeXML(kanjivg *SVG) (output []byte) {
-	kanjivg.RenumberXML()
-	output, err := xml.MarshalIndent(*kanjivg, "", "\t")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error marshalling: %s\n", err)
-		os.Exit(1)
-	}
-	output = fixXML(output)
+// Make kanjivg into the XML of the KanjiVG files. Parts of kanjivg
+// which were read from a file and have not been altered are written
+// exactly as they were in the file, and everything else is written in
+// the style of the KanjiVG files, with the common heading material if
+// kanjivg was not read from a file. The error value wraps
+// ErrNoBaseGroup if kanjivg has no base group, or ErrMarshal if an
+// attribute is in a namespace which has no prefix. To write to an
+// io.Writer, or to not renumber, use Encode.
+func MakeXML(kanjivg *SVG) (output []byte, err error) {
+	return MakeXMLOptions(kanjivg, nil)
+}
+
+// Make kanjivg into XML, or stop the program if that fails.
+func MakeXMLOrDie(kanjivg *SVG) (output []byte) {
+	output, err := MakeXML(kanjivg)
+	die(err, "Error making XML")
 	return output
 }
 
 // Write kanjivg out as a file.
-func (kanjivg *SVG) WriteKanjiFile(file string) {
-	WriteKanjiFile(file, kanjivg)
+func (kanjivg *SVG) WriteKanjiFile(file string) (err error) {
+	return WriteKanjiFile(file, kanjivg)
 }
 
-// Write kanjivg to file.
-func WriteKanjiFile(file string, kanjivg *SVG) {
-	err := os.WriteFile(file, MakeXML(kanjivg), 0644)
+// Write kanjivg to file. The file is replaced all at once, as by
+// WriteFileAtomic.
+func WriteKanjiFile(file string, kanjivg *SVG) (err error) {
+	output, err := MakeXML(kanjivg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", file, err)
-		os.Exit(1)
+		return fmt.Errorf("%s: %w", file, err)
 	}
+	return WriteFileAtomic(file, output, false)
+}
+
+// Write kanjivg to file, or stop the program if that fails.
+func WriteKanjiFileOrDie(file string, kanjivg *SVG) {
+	err := WriteKanjiFile(file, kanjivg)
+	die(err, "Error writing")
 }
 
 // Special marshaller for "child" elements, since a g may contain
@@ -190,132 +318,54 @@ func (c Child) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 		start.Name = xml.Name{Local: "text"}
 		return e.EncodeElement(c.Text, start)
 	}
+	if c.IsOther {
+		return encodeRaw(e, c.Other.Raw)
+	}
 	start.Name = xml.Name{Local: "path"}
 	return e.EncodeElement(c.Path, start)
 }
 
-// Special unmarshaller. For some reason the kvg:type parts were not
-// being picked up by the default parser, so I wrote this in order to
-// work around that. There must be something I have missed about how
-// the default unmarshal routine works.
+// Special unmarshaller. The KanjiVG files only declare the kvg prefix
+// of kvg:type in the DOCTYPE, which the XML decoder does not read, so
+// the default unmarshal routine cannot match the attribute to
+// KVGNamespace. This treats the undeclared kvg prefix as being that
+// namespace. When this is used via
+// xml.Unmarshal, the Format is not recorded, so use ParseKanji to
+// read whole files.
 func (p *Path) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
-	for _, attr := range start.Attr {
-		switch attr.Name.Local {
-		case "d":
-			p.D = attr.Value
-		case "id":
-			p.ID = attr.Value
-		case "type":
-			p.Type = attr.Value
-		}
-	}
-	for {
-		/* There is no content in the paths. */
-		token, err := d.Token()
-		if err != nil {
-			return err
-		}
-		switch el := token.(type) {
-		case xml.EndElement:
-			if el == start.End() {
-				return nil
-			}
-		}
-	}
+	ps := parser{d: d}
+	return ps.path(p, start, "")
 }
 
-// Unmarshaller for a group.
+// Unmarshaller for a group. See the note on Path.UnmarshalXML.
 func (g *Group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
-	for _, attr := range start.Attr {
-		switch attr.Name.Local {
-		case "element":
-			g.Element = attr.Value
-		case "id":
-			g.ID = attr.Value
-		case "number":
-			g.Number = attr.Value
-		case "original":
-			g.Original = attr.Value
-		case "part":
-			g.Part = attr.Value
-		case "partial":
-			if attr.Value == "true" {
-				g.Partial = true
-			}
-		case "phon":
-			g.Phon = attr.Value
-		case "position":
-			g.Position = attr.Value
-		case "radical":
-			g.Radical = attr.Value
-		case "radicalForm":
-			g.RadicalForm = attr.Value
-		case "style":
-			g.Style = attr.Value
-		case "tradForm":
-			g.TradForm = attr.Value
-		case "variant":
-			if attr.Value == "true" {
-				g.Variant = true
-			}
-		default:
-			fmt.Printf("Unhandled -> %s\n", attr.Name.Local)
-		}
-	}
-	for {
-		token, err := d.Token()
-		if err != nil {
-			return err
-		}
-		var c Child
-		c.Parent = g
-		fail := false
-		switch el := token.(type) {
-		case xml.StartElement:
-			switch el.Name.Local {
-			case "g":
-				err = d.DecodeElement(&c.Group, &el)
-				if err != nil {
-					return err
-				}
-				c.IsGroup = true
-				c.IsText = false
-				c.Group.Parent = &c
-			case "path":
-				err = d.DecodeElement(&c.Path, &el)
-				if err != nil {
-					return err
-				}
-				c.IsGroup = false
-				c.IsText = false
-				c.Path.Parent = &c
-			case "text":
-				err = d.DecodeElement(&c.Text, &el)
-				if err != nil {
-					return err
-				}
-				c.IsText = true
-				c.IsGroup = false
-				c.Text.Parent = &c
-			default:
-				fmt.Printf("Unhandled -> %s\n", el.Name.Local)
-				fail = true
-			}
-			if !fail {
-				g.Children = append(g.Children, c)
-			}
-		case xml.EndElement:
-			if el == start.End() {
-				return nil
-			}
-		}
-	}
+	ps := parser{d: d}
+	return ps.group(g, start, "")
+}
+
+// Unmarshaller for a text. See the note on Path.UnmarshalXML.
+func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	ps := parser{d: d}
+	return ps.text(t, start, "")
+}
+
+// Unmarshaller for a whole file. See the note on Path.UnmarshalXML.
+func (svg *SVG) UnmarshalXML(d *xml.Decoder, start xml.StartElement) (err error) {
+	ps := parser{d: d}
+	return ps.svg(svg, start, "")
 }
 
-// Given a kanji in "contents", parse it into kanjivg. The error value
-// comes from the XML unmarshalling.
+// Given a kanji in "contents", parse it into kanjivg. Everything in
+// contents is kept, including the heading, the indentation, and any
+// attributes or elements which this library does not know about, so
+// that if kanjivg is written out again without being altered, it is
+// the same as contents. The error value comes from the XML decoder.
 func ParseKanji(contents []byte) (kanjivg SVG, oerr error) {
-	oerr = xml.Unmarshal(contents, &kanjivg)
+	ps := parser{
+		d:   xml.NewDecoder(bytes.NewReader(contents)),
+		src: &source{buf: contents},
+	}
+	oerr = ps.file(&kanjivg)
 	if oerr != nil {
 		return kanjivg, oerr
 	}
@@ -324,11 +374,23 @@ func ParseKanji(contents []byte) (kanjivg SVG, oerr error) {
 
 // The "parent" or "base" group of an SVG. This is a pointer to a
 // value within kvg itself. See also Grab for an easy function which
-// gets both the SVG and the base group from a file.
+// gets both the SVG and the base group from a file. This panics if
+// kvg has no base group, so if kvg comes from an untrusted source,
+// check it first with HasBaseGroup.
 func (kvg *SVG) BaseGroup() (group *Group) {
 	return &kvg.Groups[0].Children[0].Group
 }
 
+// Does kvg have a base group in the expected place, the first child of
+// the first group?
+func (kvg *SVG) HasBaseGroup() bool {
+	if len(kvg.Groups) == 0 {
+		return false
+	}
+	children := kvg.Groups[0].Children
+	return len(children) > 0 && children[0].IsGroup
+}
+
 // Given a kanji file, read it and put the contents into kanjivg.
 func ReadKanjiFile(file string) (kanjivg SVG, oerr error) {
 	contents, oerr := os.ReadFile(file)
@@ -344,6 +406,9 @@ func ReadKanjiFile(file string) (kanjivg SVG, oerr error) {
 
 // Renumber the groups and strokes recursively.
 func renumber(child *Child, base string, nPathPtr, nGroupPtr *int64) {
+	if child.IsOther || child.IsText {
+		return
+	}
 	if child.IsGroup {
 		*nGroupPtr++
 		(*child).Group.ID = fmt.Sprintf("%s-g%d", base, *nGroupPtr)
@@ -372,9 +437,15 @@ func (kvg *SVG) Base() (base, baseNoKVG string) {
 // when the file is converted to XML, all of the id values in the
 // output will use this base value, so there is no need to set it for
 // each element.
-func (kvg *SVG) SetBase(base string) {
-	if base[0:4] != "kvg:" {
-		log.Fatalf("Base name '%s' does not start with 'kvg:'", base)
+//
+// The error value wraps ErrBadBase if base does not start with "kvg:",
+// or ErrNoBaseGroup if kvg has no base group.
+func (kvg *SVG) SetBase(base string) (err error) {
+	if !strings.HasPrefix(base, "kvg:") {
+		return fmt.Errorf("%w: '%s'", ErrBadBase, base)
+	}
+	if !kvg.HasBaseGroup() {
+		return ErrNoBaseGroup
 	}
 	baseGroup := kvg.BaseGroup()
 	baseGroup.ID = base
@@ -390,6 +461,14 @@ func (kvg *SVG) SetBase(base string) {
 	for i := range baseGroup.Children {
 		renumber(&baseGroup.Children[i], base, &nPath, &nGroup)
 	}
+	return nil
+}
+
+// Change the base of kvg as SetBase does, or stop the program if
+// that fails.
+func (kvg *SVG) SetBaseOrDie(base string) {
+	err := kvg.SetBase(base)
+	die(err, "Error setting base")
 }
 
 // Renumber the labels of the "text" group. The numerical labels given
@@ -397,19 +476,33 @@ func (kvg *SVG) SetBase(base string) {
 // group, so the user does not need to keep track of the original
 // numbers within the file.
 func (kvg *SVG) RenumberLabels() {
+	if len(kvg.Groups) < 2 {
+		return
+	}
 	labels := kvg.Groups[1]
+	n := 0
 	for i := range labels.Children {
 		c := &labels.Children[i]
+		if c.IsOther {
+			continue
+		}
 		if !c.IsText {
 			fmt.Fprintf(os.Stderr, "Error: non-text child in label %d\n", i+1)
 			continue
 		}
-		c.Text.Content = []byte(fmt.Sprintf("%d", i+1))
+		n++
+		c.Text.Content = []byte(fmt.Sprintf("%d", n))
 	}
 }
 
 // Renumber an XML file read into "kvg".
 func (svg *SVG) RenumberXML() {
+	svg.renumberIDs()
+	svg.RenumberLabels()
+}
+
+// Renumber the ids of the groups and paths of svg.
+func (svg *SVG) renumberIDs() {
 	var nPath int64
 	var nGroup int64
 	baseGroup := svg.BaseGroup()
@@ -417,14 +510,17 @@ func (svg *SVG) RenumberXML() {
 	for i := range baseGroup.Children {
 		renumber(&baseGroup.Children[i], base, &nPath, &nGroup)
 	}
-	svg.RenumberLabels()
 }
 
-// Read, renumber, and then write out a kanji file.
-func RenumberFile(file string) {
-	kvg := ReadKanjiFileOrDie(file)
-	kvg.RenumberXML()
-	WriteKanjiFile(file, &kvg)
+// Read, renumber, and then write out a kanji file in the style of the
+// KanjiVG files.
+func RenumberFile(file string) (err error) {
+	kvg, err := ReadKanjiFile(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	kvg.ClearFormat()
+	return WriteKanjiFile(file, &kvg)
 }
 
 // Helper for FindMultiElement
@@ -525,12 +621,24 @@ func getPaths(g *Group) (paths []*Path) {
 			paths = append(paths, gpaths...)
 			continue
 		}
+		if c.IsOther {
+			continue
+		}
 		paths = append(paths, &c.Path)
 	}
 	return paths
 }
 
-// Remove the KVDir prefix from a file name.
+// Remove the child with index i from g. The parent pointers within
+// the children which are left are kept pointing to the right places.
+// The children of g are then indented in the KanjiVG style when
+// written, since their number has changed.
+func (g *Group) RemoveChild(i int) {
+	g.Children = append(g.Children[:i], g.Children[i+1:]...)
+	g.linkParents()
+}
+
+// Remove the KVDir prefix from a file name. See also Corpus.Rel.
 func TFile(file string) string {
 	return strings.TrimPrefix(file, KVDir+"/")
 }
@@ -601,22 +709,30 @@ var pathIDRe = regexp.MustCompile("kvg:" + hexID + ".*-s([0-9]+)")
 var fileIDRe = regexp.MustCompile(".*" + hexID + "(?:-.+)?\\.svg$")
 var filePartRe = regexp.MustCompile("(?:.*/)?(" + hexID + "(?:-([A-Za-z][A-Za-z0-9]*))?)\\.svg$")
 
-// Hexadecimal to number. We already know hexID is valid from the
-// regex validation, so we can fail fatally if this fails.
-func HexIDToNum(hexID string) (num int64) {
-	num, err := strconv.ParseInt(hexID, 16, 64)
+// Hexadecimal to number. The error value is from strconv.ParseInt.
+func HexIDToNum(hexID string) (num int64, err error) {
+	return strconv.ParseInt(hexID, 16, 64)
+}
+
+// Hexadecimal to number, for when we already know hexID is valid from
+// the regex validation, so we can fail fatally if this fails.
+func HexIDToNumOrDie(hexID string) (num int64) {
+	num, err := HexIDToNum(hexID)
 	die(err, "Error parsing hex number")
 	return num
 }
 
 // Given a KanjiVG file name fileName, return the hexadecimal id
-// number, the kanji as a number, and the extension.
+// number, the kanji as a number, and the extension. If fileName is not
+// a KanjiVG file name, all the return values are empty.
 func FileToParts(fileName string) (id string, num int64, extension string) {
 	match := filePartRe.FindStringSubmatch(fileName)
 	if len(match) == 0 {
 		return "", 0, ""
 	}
-	num = HexIDToNum(match[2])
+	// The regex only matches five hexadecimal digits, so this cannot
+	// fail.
+	num, _ = HexIDToNum(match[2])
 	return match[1], num, match[3]
 }
 
@@ -687,12 +803,20 @@ func ExamineKanjiFile(path string, d fs.DirEntry, fn SVGFunc) (oerr error) {
 // first. This is usually better if you want to just check some files,
 // since you can check the Unicode ID of the character using
 // FileToNum, and decide whether to read it all in, rather than
-// reading everything for all files.
-func ExamineAllFiles(fn SVGFileFunc) {
-	filepath.WalkDir(KVDir, func(path string, d fs.DirEntry, err error) error {
+// reading everything for all files. To examine the files using all
+// the CPUs, use WalkDir. The error value is from walking the
+// directory.
+func ExamineAllFiles(fn SVGFileFunc) (err error) {
+	return filepath.WalkDir(KVDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 		if d.IsDir() {
 			return nil
 		}
+		if Backup.MatchString(path) {
+			return nil
+		}
 		kanjivg := ReadKanjiFileOrDie(path)
 		fn(path, kanjivg)
 		return nil
@@ -701,9 +825,13 @@ func ExamineAllFiles(fn SVGFileFunc) {
 
 // Examine all the files in KVDir. Before using this, set KVDir to the
 // value on your system. It will go through each file and call fn on
-// them. It doesn't read the contents, unlike ExamineAllFiles.
-func ExamineAllFilesSimple(fn func(file string)) {
-	filepath.WalkDir(KVDir, func(path string, d fs.DirEntry, err error) error {
+// them. It doesn't read the contents, unlike ExamineAllFiles. The
+// error value is from walking the directory.
+func ExamineAllFilesSimple(fn func(file string)) (err error) {
+	return filepath.WalkDir(KVDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -715,16 +843,25 @@ func ExamineAllFilesSimple(fn func(file string)) {
 	})
 }
 
-// Get just the Unicode number from a kanjivg file name.
-func FileToNum(fileName string) (num int64) {
+// Get just the Unicode number from a kanjivg file name. The error
+// value wraps ErrBadFileName if fileName does not look like a KanjiVG
+// file.
+func FileToNum(fileName string) (num int64, err error) {
 	match := fileIDRe.FindStringSubmatch(fileName)
 	if len(match) == 0 {
-		fmt.Printf("No match in %s\n", fileName)
-		os.Exit(1)
+		return 0, fmt.Errorf("%w: %s", ErrBadFileName, fileName)
 	}
 	return HexIDToNum(match[1])
 }
 
+// Get just the Unicode number from a kanjivg file name, or stop the
+// program if it is not a KanjiVG file name.
+func FileToNumOrDie(fileName string) (num int64) {
+	num, err := FileToNum(fileName)
+	die(err, "Error getting number")
+	return num
+}
+
 func die(err error, format string, a ...any) {
 	if err == nil {
 		return
@@ -772,6 +909,9 @@ func FindType(g *Group, t string) (found bool, loc []*Child) {
 			}
 			continue
 		}
+		if c.IsOther {
+			continue
+		}
 		gc := &g.Children[i]
 		if gc.Path.Type == t {
 			return true, []*Child{gc}
@@ -784,7 +924,7 @@ func (c *Child) Dump() (s string) {
 	s += fmt.Sprintf("IsGroup: %t\n", c.IsGroup)
 	s += fmt.Sprintf("IsText: %t\n", c.IsText)
 	s += fmt.Sprintf("Group:%s\n", c.Group.Dump())
-	s += fmt.Sprintf("Text:%s\n", c.Text)
+	s += fmt.Sprintf("Text:%s\n", c.Text.Content)
 	return s
 }
 
@@ -797,6 +937,9 @@ func (g *Group) dump(depth int) (s string) {
 			s += c.Group.dump(depth + 1)
 			continue
 		}
+		if c.IsOther {
+			continue
+		}
 		s += fmt.Sprintf("%s  %s %s\n", indent, c.Path.ID, c.Path.Type)
 	}
 	return s
@@ -814,18 +957,19 @@ func (svg *SVG) GetPaths() (paths []*Path) {
 	return getPaths(base)
 }
 
-// Get the numeric part of a path ID.
-func PathIDToNum(id string) (num int64) {
+// Get the numeric part of a path ID. The error value wraps ErrBadID if
+// id is not a KanjiVG path ID.
+func PathIDToNum(id string) (num int64, err error) {
 	match := pathIDRe.FindStringSubmatch(id)
+	if len(match) == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrBadID, id)
+	}
 	return decimalToNum(match[2])
 }
 
-// Decimal to number. We already know it is valid from the regex
-// validation so we can fail fatally if this fails.
-func decimalToNum(Decimal string) (num int64) {
-	num, err := strconv.ParseInt(Decimal, 10, 64)
-	die(err, "Error parsing decimal")
-	return num
+// Decimal to number. The error value is from strconv.ParseInt.
+func decimalToNum(Decimal string) (num int64, err error) {
+	return strconv.ParseInt(Decimal, 10, 64)
 }
 
 // Given a group g, return its element
@@ -1,10 +1,14 @@
 package kvg
 
 import (
+	"encoding/xml"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func bin() string {
@@ -30,7 +34,11 @@ func TestWriteKanjiFile(t *testing.T) {
 		return
 	}
 	ofile := bin + "/testoutput.svg"
-	svg.WriteKanjiFile(ofile)
+	err = svg.WriteKanjiFile(ofile)
+	if err != nil {
+		t.Errorf("Error writing %s: %s", ofile, err)
+		return
+	}
 	a := read(infile)
 	b := read(ofile)
 	if a != b {
@@ -39,3 +47,266 @@ func TestWriteKanjiFile(t *testing.T) {
 	err = os.Remove(ofile)
 	die(err, "Error removing %s", ofile)
 }
+
+func TestErrors(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatalf("Error reading: %s", err)
+	}
+	err = svg.SetBase("08475")
+	if !errors.Is(err, ErrBadBase) {
+		t.Errorf("SetBase with bad base gave %v, expected ErrBadBase", err)
+	}
+	err = svg.SetBase("")
+	if !errors.Is(err, ErrBadBase) {
+		t.Errorf("SetBase with empty base gave %v, expected ErrBadBase", err)
+	}
+	_, err = FileToNum("not-a-kanji.txt")
+	if !errors.Is(err, ErrBadFileName) {
+		t.Errorf("FileToNum with bad name gave %v, expected ErrBadFileName", err)
+	}
+	num, err := FileToNum("/some/dir/08475-Kaisho.svg")
+	if err != nil || num != 0x8475 {
+		t.Errorf("FileToNum gave %X, %v", num, err)
+	}
+	_, err = PathIDToNum("kvg:08475-g1")
+	if !errors.Is(err, ErrBadID) {
+		t.Errorf("PathIDToNum with group ID gave %v, expected ErrBadID", err)
+	}
+	num, err = PathIDToNum("kvg:08475-s12")
+	if err != nil || num != 12 {
+		t.Errorf("PathIDToNum gave %d, %v", num, err)
+	}
+	var empty SVG
+	_, err = empty.MakeXML()
+	if !errors.Is(err, ErrNoBaseGroup) {
+		t.Errorf("MakeXML of empty SVG gave %v, expected ErrNoBaseGroup", err)
+	}
+}
+
+func TestHouseStyle(t *testing.T) {
+	infile := bin() + "/t/08475.svg"
+	svg, err := ReadKanjiFile(infile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg.ClearFormat()
+	out, err := svg.MakeXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != read(infile) {
+		t.Errorf("House style output of %s differs from the file:\n%s", infile, out)
+	}
+}
+
+// A file which is not in the KanjiVG style, with things this library
+// does not know about.
+var oddFile = `<?xml version="1.0"?>
+<!-- Not the usual heading -->
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:x="http://example.com/x" width="109" height="109" x:thing="1">
+  <title>Odd &amp; unusual</title>
+  <g id="kvg:StrokePaths_04e00" style="fill:none" data-foo='bar'>
+    <g id="kvg:04e00" kvg:element="一" x:note="a&quot;b">
+      <!-- The only stroke -->
+      <path id="kvg:04e00-s1" kvg:type="㇐" d="M11,54.25c3.19,0.62,6.25,0.75,9.73,0.5" x:width="3" ></path>
+      <x:marker at="1"/>
+      <g id="kvg:04e00-g1"/>
+    </g>
+  </g>
+  <g id="kvg:StrokeNumbers_04e00" style="font-size:8">
+    <text transform="matrix(1 0 0 1 4.25 54.13)">&#49;</text>
+  </g>
+</svg>`
+
+func TestLossless(t *testing.T) {
+	svg, err := ParseKanji([]byte(oddFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := svg.MakeXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != oddFile {
+		t.Errorf("Output differs from input:\n%s", out)
+	}
+	base := svg.BaseGroup()
+	if len(base.Children) != 4 || !base.Children[0].IsOther ||
+		!base.Children[2].IsOther || len(base.GetPaths()) != 1 {
+		t.Errorf("Wrong children %s", base.Dump())
+	}
+	// Alter the file, and check that the unknown parts are kept.
+	base.Children = base.Children[1:]
+	base.Element = "二"
+	out, err = svg.MakeXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		`<title>Odd &amp; unusual</title>`,
+		`<g id="kvg:04e00" kvg:element="二" x:note="a&#34;b">`,
+		"\n\t<path id=\"kvg:04e00-s1\" kvg:type=\"㇐\" d=\"M11,54.25c3.19,0.62,6.25,0.75,9.73,0.5\" x:width=\"3\" ></path>",
+		"\n\t<x:marker at=\"1\"/>\n\t<g id=\"kvg:04e00-g1\"/>\n</g>",
+		`<text transform="matrix(1 0 0 1 4.25 54.13)">&#49;</text>`,
+	}
+	for _, e := range expect {
+		if !strings.Contains(string(out), e) {
+			t.Errorf("Output does not contain %s:\n%s", e, out)
+		}
+	}
+	// The unknown parts are also kept by xml.Unmarshal.
+	var svg2 SVG
+	err = xml.Unmarshal([]byte(oddFile), &svg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svg2.Extra) != 2 || len(svg2.BaseGroup().Children) != 4 {
+		t.Errorf("Unknown parts not kept by xml.Unmarshal")
+	}
+}
+
+func TestSource(t *testing.T) {
+	contents := read(bin() + "/t/08475.svg")
+	svg, err := ParseKanji([]byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := func(s SourceSpan) string {
+		return contents[s.Offset:s.End]
+	}
+	base := svg.BaseGroup().Source()
+	if base.Line != 39 || base.Column != 1 ||
+		!strings.HasPrefix(text(base), `<g id="kvg:08475" kvg:element="葵">`) ||
+		!strings.HasSuffix(text(base), "</g>") {
+		t.Errorf("Wrong base group source %s %q", base, text(base))
+	}
+	var s6 *Path
+	for _, p := range svg.GetPaths() {
+		if p.ID == "kvg:08475-s6" {
+			s6 = p
+		}
+	}
+	path := s6.Source()
+	// The column is after four tabs, and the end is after a kana in
+	// the type.
+	if path.Line != 52 || path.Column != 5 || path.EndLine != 52 ||
+		path.EndColumn != 5+utf8.RuneCountInString(text(path)) ||
+		!strings.HasPrefix(text(path), `<path id="kvg:08475-s6"`) ||
+		!strings.HasSuffix(text(path), "/>") {
+		t.Errorf("Wrong path source %s %q", path, text(path))
+	}
+	label := svg.Groups[1].Children[0].Text.Source()
+	if !strings.HasPrefix(text(label), "<text") || !strings.HasSuffix(text(label), ">1</text>") {
+		t.Errorf("Wrong text source %s %q", label, text(label))
+	}
+	if svg.Source().Line == 0 || !svg.Groups[0].Source().IsValid() {
+		t.Errorf("No source for the svg or the first group")
+	}
+	svg.ClearFormat()
+	if svg.BaseGroup().Source().IsValid() {
+		t.Errorf("Source not cleared")
+	}
+	var svg2 SVG
+	err = xml.Unmarshal([]byte(contents), &svg2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svg2.BaseGroup().Source().IsValid() {
+		t.Errorf("Source from xml.Unmarshal")
+	}
+}
+
+var nsFile = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:k="http://kanjivg.tagaini.net" xmlns:x="http://example.com/x" width="109" height="109">
+<g id="kvg:StrokePaths_04e00" style="fill:none">
+<g id="kvg:04e00" k:element="一" x:element="other" element="plain">
+	<path id="kvg:04e00-s1" k:type="㇐" d="M11,54.25c3.19,0.62,6.25,0.75,9.73,0.5"/>
+</g>
+</g>
+</svg>
+`
+
+func TestNamespaces(t *testing.T) {
+	svg, err := ParseKanji([]byte(nsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := svg.BaseGroup()
+	if base.Element != "一" || len(base.Extra) != 2 {
+		t.Errorf("Wrong element %q or extra attributes %v", base.Element, base.Extra)
+	}
+	if svg.GetPaths()[0].Type != "㇐" {
+		t.Errorf("Stroke type in declared namespace not read")
+	}
+	out, err := svg.MakeXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != nsFile {
+		t.Errorf("Output differs from input:\n%s", out)
+	}
+	base.Element = "二"
+	out, err = svg.MakeXML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := `<g id="kvg:04e00" k:element="二" x:element="other" element="plain">`
+	if !strings.Contains(string(out), e) {
+		t.Errorf("Output does not contain %s:\n%s", e, out)
+	}
+	// Attributes in a namespace with no prefix cannot be written.
+	base.Extra = append(base.Extra, xml.Attr{
+		Name: xml.Name{Space: "http://example.com/y", Local: "z"},
+	})
+	_, err = svg.MakeXML()
+	if !errors.Is(err, ErrMarshal) {
+		t.Errorf("Expected ErrMarshal, got %v", err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	contents := read(bin() + "/t/08475.svg")
+	svg, err := Decode(strings.NewReader(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	err = svg.Encode(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != contents {
+		t.Errorf("Encode changed the file")
+	}
+	// Move the first stroke to the end, and check the options.
+	base := svg.BaseGroup()
+	base.Children = append(base.Children[1:], base.Children[0])
+	labels := &svg.Groups[1].Children[1].Text
+	labels.Content = []byte("x")
+	buf.Reset()
+	err = svg.Encode(&buf, &EncodeOptions{
+		NoRenumber:       true,
+		NoRenumberLabels: true,
+		NoHeading:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "<svg ") {
+		t.Errorf("Heading not removed")
+	}
+	if !strings.Contains(out, `<g id="kvg:08475-g1" kvg:element="艹"`) ||
+		!strings.Contains(out, ">x</text>") {
+		t.Errorf("Elements were renumbered:\n%s", out)
+	}
+	buf.Reset()
+	err = svg.Encode(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = buf.String()
+	if !strings.HasPrefix(out, "<?xml") || strings.Contains(out, ">x</text>") {
+		t.Errorf("Not renumbered:\n%s", out)
+	}
+}
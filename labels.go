@@ -0,0 +1,234 @@
+package kvg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Options for placing the stroke number labels. The zero value places
+// them as in the KanjiVG files.
+type LabelOptions struct {
+	// The font size of the labels. If this is zero, the font-size in
+	// the style of the StrokeNumbers group is used, or 8.
+	FontSize float64
+	// The space between a label and the edge of a stroke. If this is
+	// zero, 1 is used.
+	Gap float64
+}
+
+// The width and height of a digit of the labels, in proportion to the
+// font size, as in the usual sans-serif fonts.
+const (
+	labelDigitWidth  = 0.56
+	labelDigitHeight = 0.72
+)
+
+// The directions tried for a label, in degrees from straight back
+// along the start of the stroke, in order of preference.
+var labelAngles = []float64{0, -30, 30, -60, 60, -90, 90, -120, 120, -150, 150, 180}
+
+// The distances tried for a label, in proportion to the nearest that
+// a label can be to the start of its stroke.
+var labelDistances = []float64{1, 1.5, 2, 3}
+
+// The costs used to choose between the places for a label.
+const (
+	// For each point of a stroke under the label.
+	labelStrokeCost = 10
+	// For overlapping another label.
+	labelLabelCost = 1000
+	// For going outside the viewBox.
+	labelOutsideCost = 1000
+	// For each step away from the preferred direction.
+	labelAngleCost = 1
+	// For each step away from the start of the stroke.
+	labelDistanceCost = 2
+)
+
+// The spacing of the points of the strokes which labels avoid.
+const labelSpacing = 0.5
+
+// Places the stroke number labels of a kanji one after the other.
+type labelPlacer struct {
+	size float64
+	gap  float64
+	hw   float64
+	box  Rect
+	// Points along all of the strokes.
+	points []Point
+	// The labels placed so far.
+	placed []Rect
+}
+
+// Make a placer of the labels of kanjivg.
+func newLabelPlacer(kanjivg *SVG, opts *LabelOptions) (lp *labelPlacer, err error) {
+	if opts == nil {
+		opts = &LabelOptions{}
+	}
+	lp = &labelPlacer{
+		size: opts.FontSize,
+		gap:  opts.Gap,
+		hw:   strokeWidth(kanjivg) / 2,
+		box:  kanjivg.viewBox(),
+	}
+	if lp.size <= 0 {
+		if labels := kanjivg.labels(); labels != nil {
+			lp.size, _ = strconv.ParseFloat(styleProperty(labels.Style, "font-size"), 64)
+		}
+		if lp.size <= 0 {
+			lp.size = 8
+		}
+	}
+	if lp.gap <= 0 {
+		lp.gap = 1
+	}
+	for _, p := range kanjivg.GetPaths() {
+		path, err := p.SVGPath()
+		if err != nil {
+			return nil, &PathError{ID: p.ID, Err: err}
+		}
+		n := int(math.Ceil(path.Length()/labelSpacing)) + 1
+		lp.points = append(lp.points, path.Resample(n)...)
+	}
+	return lp, nil
+}
+
+// The box of the label text with the left end of its baseline at pos.
+func (lp *labelPlacer) labelBox(text string, pos Point) Rect {
+	w := float64(len(text)) * labelDigitWidth * lp.size
+	h := labelDigitHeight * lp.size
+	return Rect{Point{pos.X, pos.Y - h}, Point{pos.X + w, pos.Y}}
+}
+
+// The cost of putting a label in box r.
+func (lp *labelPlacer) cost(r Rect) (cost float64) {
+	if r.Min.X < lp.box.Min.X || r.Min.Y < lp.box.Min.Y ||
+		r.Max.X > lp.box.Max.X || r.Max.Y > lp.box.Max.Y {
+		cost += labelOutsideCost
+	}
+	// Keep the gap from the edges of the strokes.
+	d := lp.hw + lp.gap
+	for _, p := range lp.points {
+		if p.X > r.Min.X-d && p.X < r.Max.X+d && p.Y > r.Min.Y-d && p.Y < r.Max.Y+d {
+			cost += labelStrokeCost
+		}
+	}
+	for _, q := range lp.placed {
+		if r.Min.X < q.Max.X+lp.gap && q.Min.X < r.Max.X+lp.gap &&
+			r.Min.Y < q.Max.Y+lp.gap && q.Min.Y < r.Max.Y+lp.gap {
+			cost += labelLabelCost
+		}
+	}
+	return cost
+}
+
+// Find the position of the label text of the stroke path, preferring
+// a place just behind the start of the stroke, then places around it,
+// avoiding the strokes and the labels already placed.
+func (lp *labelPlacer) place(text string, path SVGPath) (pos Point) {
+	start := path.Start()
+	back := path.StartTangent().scale(-1)
+	if back.Len() == 0 {
+		// Up and to the left, as for a dot.
+		back = Point{-1, -1}.unit()
+	}
+	size := lp.labelBox(text, Point{})
+	half := Point{size.Width() / 2, size.Height() / 2}
+	// The distance from the start to the middle of the label, when it
+	// just touches the edge of the stroke.
+	near := lp.hw + lp.gap + half.Len()
+	best := math.Inf(1)
+	for i, angle := range labelAngles {
+		s, c := math.Sincos(angle * math.Pi / 180)
+		dir := Point{back.X*c - back.Y*s, back.X*s + back.Y*c}
+		for j, dist := range labelDistances {
+			middle := start.add(dir.scale(near * dist))
+			at := Point{middle.X - half.X, middle.Y + half.Y}
+			cost := lp.cost(lp.labelBox(text, at)) +
+				labelAngleCost*float64(i) + labelDistanceCost*float64(j)
+			if cost < best {
+				best, pos = cost, at
+			}
+		}
+	}
+	lp.placed = append(lp.placed, lp.labelBox(text, pos))
+	return pos
+}
+
+// Work out where the stroke number label of each stroke of kanjivg
+// goes, near the start of the stroke and behind it, avoiding the other
+// strokes and labels. Each position is the left end of the baseline of
+// the label, as in the transform of the text. The error is a PathError
+// if a stroke cannot be parsed.
+func LabelPositions(kanjivg *SVG, opts *LabelOptions) (positions []Point, err error) {
+	lp, err := newLabelPlacer(kanjivg, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range kanjivg.GetPaths() {
+		path, err := p.SVGPath()
+		if err != nil {
+			return nil, &PathError{ID: p.ID, Err: err}
+		}
+		positions = append(positions, lp.place(strconv.Itoa(i+1), path))
+	}
+	return positions, nil
+}
+
+// Move the existing stroke number labels of kanjivg to the positions
+// given by LabelPositions. The label of a stroke is found as by
+// Group.Label. If there is no StrokeNumbers group, nothing is done.
+func (kanjivg *SVG) PlaceLabels(opts *LabelOptions) (err error) {
+	labels := kanjivg.labels()
+	if labels == nil {
+		return nil
+	}
+	positions, err := LabelPositions(kanjivg, opts)
+	if err != nil {
+		return err
+	}
+	for i, p := range kanjivg.GetPaths() {
+		if t := labels.Label(p); t != nil {
+			t.SetPosition(positions[i])
+		}
+	}
+	return nil
+}
+
+// Replace the StrokeNumbers group of kanjivg with a new one, with a
+// label for each stroke in order, placed by LabelPositions. If there
+// is no StrokeNumbers group, one is added with the usual id and style.
+// The error is a PathError if a stroke cannot be parsed, or
+// ErrNoBaseGroup.
+func (kanjivg *SVG) MakeLabels(opts *LabelOptions) (err error) {
+	if !kanjivg.HasBaseGroup() {
+		return ErrNoBaseGroup
+	}
+	positions, err := LabelPositions(kanjivg, opts)
+	if err != nil {
+		return err
+	}
+	if len(kanjivg.Groups) < 2 {
+		_, baseNoKVG := kanjivg.Base()
+		kanjivg.Groups = append(kanjivg.Groups, Group{
+			ID:    "kvg:StrokeNumbers_" + baseNoKVG,
+			Style: StrokeNumbersStyle,
+		})
+		// The append may have moved the groups, so the parents of
+		// their children must be set again.
+		for i := range kanjivg.Groups {
+			kanjivg.Groups[i].linkParents()
+		}
+	}
+	labels := &kanjivg.Groups[1]
+	labels.Children = nil
+	labels.Format.Space = nil
+	for i, pos := range positions {
+		t := Text{Content: []byte(fmt.Sprintf("%d", i+1))}
+		t.SetPosition(pos)
+		labels.Children = append(labels.Children, Child{Text: t, IsText: true})
+	}
+	labels.linkParents()
+	return nil
+}
@@ -0,0 +1,108 @@
+package kvg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLabels(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Start again with no StrokeNumbers group.
+	svg.Groups = svg.Groups[:1]
+	err = svg.MakeLabels(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A file read with no StrokeNumbers group has no room for one, so
+	// the groups are moved, and the parents must follow them.
+	contents := read(bin() + "/t/08475.svg")
+	start := strings.Index(contents, `<g id="kvg:StrokeNumbers_08475"`)
+	end := strings.Index(contents[start:], "</g>\n") + start + len("</g>\n")
+	unlabelled, err := ParseKanji([]byte(contents[:start] + contents[end:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unlabelled.Groups) != 1 {
+		t.Fatalf("Wrong groups %d", len(unlabelled.Groups))
+	}
+	err = unlabelled.MakeLabels(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range unlabelled.GetPaths() {
+		g := p.Parent.Parent
+		for g.Parent != nil {
+			g = g.Parent.Parent
+		}
+		if g != &unlabelled.Groups[0] {
+			t.Fatalf("Parent of %s is not in the groups", p.ID)
+		}
+	}
+	labels := svg.labels()
+	if labels == nil || labels.ID != "kvg:StrokeNumbers_08475" || labels.Style != StrokeNumbersStyle {
+		t.Fatalf("Wrong StrokeNumbers group %+v", labels)
+	}
+	lp, err := newLabelPlacer(&svg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var boxes []Rect
+	for i, p := range svg.GetPaths() {
+		text := labels.Label(p)
+		if text == nil || string(text.Content) != fmt.Sprint(i+1) {
+			t.Fatalf("Wrong label for stroke %d", i+1)
+		}
+		pos, err := text.Position()
+		if err != nil {
+			t.Fatal(err)
+		}
+		box := lp.labelBox(string(text.Content), pos)
+		if box.Union(lp.box) != lp.box {
+			t.Errorf("Label %d at %v is outside", i+1, pos)
+		}
+		path, err := p.SVGPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		middle := box.Min.add(box.Max).scale(0.5)
+		if d := middle.sub(path.Start()).Len(); d > 20 {
+			t.Errorf("Label %d is %g from its stroke", i+1, d)
+		}
+		for j, b := range boxes {
+			if box.Min.X < b.Max.X && b.Min.X < box.Max.X && box.Min.Y < b.Max.Y && b.Min.Y < box.Max.Y {
+				t.Errorf("Labels %d and %d overlap", j+1, i+1)
+			}
+		}
+		boxes = append(boxes, box)
+	}
+	// The new group is written in the KanjiVG style.
+	out, err := MakeXML(&svg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\n\t<text transform=\"matrix(1 0 0 1 "
+	if bytes.Count(out, []byte(want)) != 12 {
+		t.Errorf("Labels are not written in the KanjiVG style:\n%s", out)
+	}
+
+	// PlaceLabels moves the labels of the file to the same places.
+	orig, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = orig.PlaceLabels(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range orig.GetPaths() {
+		got := orig.labels().Label(p).Transform
+		if want := labels.Label(svg.GetPaths()[i]).Transform; got != want {
+			t.Errorf("Label %d placed at %s not %s", i+1, got, want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kvg"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// What identifies an issue in a baseline. The line and column are not
+// part of it, so that changes elsewhere in a file do not make its old
+// issues look new, and the file is only the file name, so that a
+// baseline can be used with a copy of the corpus in another directory.
+type baselineKey struct {
+	File    string `json:"file"`
+	ID      string `json:"id,omitempty"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func keyOf(i *Issue) baselineKey {
+	return baselineKey{
+		File:    filepath.Base(i.File),
+		ID:      i.ID,
+		Rule:    i.Rule,
+		Message: i.Message,
+	}
+}
+
+// The issues which are known to exist, so that only new ones are
+// reported. Each issue in the baseline accounts for one issue found,
+// so if the same issue is found once more than before, it is reported.
+type Baseline struct {
+	counts map[baselineKey]int
+}
+
+// Make an empty baseline.
+func NewBaseline() *Baseline {
+	return &Baseline{counts: make(map[baselineKey]int)}
+}
+
+// Add i to the baseline.
+func (b *Baseline) Add(i *Issue) {
+	b.counts[keyOf(i)]++
+}
+
+// Is i in the baseline? If it is, it is taken out, so that the same
+// issue found again is not known, unless the baseline has it twice.
+func (b *Baseline) Known(i *Issue) bool {
+	k := keyOf(i)
+	if b.counts[k] == 0 {
+		return false
+	}
+	b.counts[k]--
+	return true
+}
+
+// The number of issues in the baseline.
+func (b *Baseline) Len() (n int) {
+	for _, c := range b.counts {
+		n += c
+	}
+	return n
+}
+
+// A baseline as written to a file.
+type baselineFile struct {
+	Issues []baselineKey `json:"issues"`
+}
+
+// Write the baseline as JSON, with the issues in order of file name,
+// so that a new baseline can be compared with an old one.
+func (b *Baseline) Write(w io.Writer) error {
+	bf := baselineFile{Issues: []baselineKey{}}
+	for k, c := range b.counts {
+		for ; c > 0; c-- {
+			bf.Issues = append(bf.Issues, k)
+		}
+	}
+	sort.Slice(bf.Issues, func(i, j int) bool {
+		x, y := bf.Issues[i], bf.Issues[j]
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		if x.ID != y.ID {
+			return x.ID < y.ID
+		}
+		if x.Rule != y.Rule {
+			return x.Rule < y.Rule
+		}
+		return x.Message < y.Message
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(&bf)
+}
+
+// Write the baseline to file, replacing it all at once.
+func (b *Baseline) WriteFile(file string) error {
+	var buf bytes.Buffer
+	err := b.Write(&buf)
+	if err != nil {
+		return err
+	}
+	return kvg.WriteFileAtomic(file, buf.Bytes(), false)
+}
+
+// Read a baseline written by Baseline.Write.
+func ReadBaseline(file string) (b *Baseline, err error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var bf baselineFile
+	err = json.Unmarshal(contents, &bf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	b = NewBaseline()
+	for _, k := range bf.Issues {
+		b.counts[k]++
+	}
+	return b, nil
+}
+
+// An exception to the rules, for issues which are not problems. The
+// empty fields match anything, but at least one of File, ID and
+// Element must be given.
+type Suppression struct {
+	// The file name, such as "08475.svg", or a pattern for
+	// filepath.Match, such as "08863*.svg" for all the variants of a
+	// kanji.
+	File string `json:"file,omitempty"`
+	// The id of the element with the issue.
+	ID string `json:"id,omitempty"`
+	// The kvg:element of the group with the issue, for exceptions
+	// which apply to a part wherever it is.
+	Element string `json:"element,omitempty"`
+	// The name of the rule.
+	Rule string `json:"rule,omitempty"`
+	// Why the issue is not a problem.
+	Reason string `json:"reason"`
+}
+
+// Does s match the issue i found in kf? If kf is nil, because the file
+// could not be read, a suppression with an Element does not match.
+func (s *Suppression) Matches(kf *kvg.KanjiFile, i *Issue) bool {
+	if len(s.Rule) > 0 && s.Rule != i.Rule {
+		return false
+	}
+	if len(s.ID) > 0 && s.ID != i.ID {
+		return false
+	}
+	if len(s.File) > 0 {
+		match, err := filepath.Match(s.File, filepath.Base(i.File))
+		if err != nil || !match {
+			return false
+		}
+	}
+	if len(s.Element) > 0 {
+		if kf == nil {
+			return false
+		}
+		g := findGroup(&kf.SVG, i.ID)
+		if g == nil || g.Element != s.Element {
+			return false
+		}
+	}
+	return true
+}
+
+// Read a file of suppressions, which is a JSON array of Suppression
+// objects. Each must have a reason, say what it applies to, and name
+// a known rule, if it names one.
+func ReadSuppressions(file string) (ss []Suppression, err error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contents, &ss)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for n, s := range ss {
+		switch {
+		case len(s.Reason) == 0:
+			err = errors.New("no reason")
+		case len(s.File) == 0 && len(s.ID) == 0 && len(s.Element) == 0:
+			err = errors.New("no file, id or element")
+		case len(s.File) > 0:
+			_, err = filepath.Match(s.File, "")
+		}
+		if err == nil && len(s.Rule) > 0 && s.Rule != ParseRule {
+			_, err = NewRule(s.Rule)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: suppression %d: %w", file, n+1, err)
+		}
+	}
+	return ss, nil
+}
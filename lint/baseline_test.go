@@ -0,0 +1,112 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// A file with three issues: the base group has a position, the top
+// part does not, and the formatting is not standard.
+func baselineTest(t *testing.T) []byte {
+	return readTest(t, "<svg ", "<svg  ",
+		`<g id="kvg:08475" kvg:element="葵">`, `<g id="kvg:08475" kvg:element="葵" kvg:position="top">`,
+		`kvg:element="艹" kvg:variant="true" kvg:original="艸" kvg:position="top"`,
+		`kvg:element="艹" kvg:variant="true" kvg:original="艸"`)
+}
+
+func TestBaseline(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "baseline.json")
+	issues := lintTest(t, "08475.svg", baselineTest(t))
+	if len(issues) != 3 {
+		t.Fatalf("Wrong issues %v", issues)
+	}
+	b := NewBaseline()
+	for i := range issues {
+		if issues[i].Rule != "format" {
+			b.Add(&issues[i])
+		}
+	}
+	if err := b.WriteFile(file); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadBaseline(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 2 {
+		t.Fatalf("Wrong number of issues %d in baseline", b.Len())
+	}
+	// The same issues in another directory are known, apart from the
+	// one not in the baseline, and a second copy of a known one.
+	issues = append(issues, issues[0])
+	var left []string
+	for i := range issues {
+		issues[i].File = "elsewhere/08475.svg"
+		if !b.Known(&issues[i]) {
+			left = append(left, issues[i].Rule)
+		}
+	}
+	if strings.Join(left, ",") != "format,"+issues[0].Rule {
+		t.Errorf("Wrong issues not in baseline %v", left)
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "suppressions.json")
+	write := func(s string) {
+		if err := os.WriteFile(file, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`[
+ {"element": "艹", "rule": "missing-position", "reason": "test"},
+ {"file": "08475*.svg", "rule": "format", "reason": "test"}
+]`)
+	ss, err := ReadSuppressions(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Suppressions = ss
+	l.Baseline = NewBaseline()
+	contents := baselineTest(t)
+	issues := lintTest(t, "08475.svg", contents)
+	for i := range issues {
+		if issues[i].Rule == "base-position" {
+			l.Baseline.Add(&issues[i])
+		}
+	}
+	kf := lintFile(t, "08475.svg", contents)
+	if left := l.CheckFile(kf); len(left) != 0 || l.Hidden != 3 {
+		t.Errorf("Wrong issues %v, %d hidden", left, l.Hidden)
+	}
+	// The baseline has been used up, and the file pattern does not
+	// match another kanji.
+	kf = lintFile(t, "08476.svg", contents)
+	var rules []string
+	for _, i := range l.CheckFile(kf) {
+		rules = append(rules, i.Rule)
+	}
+	if strings.Join(rules, ",") != "base-element,base-position,format,ids,ids,ids" {
+		t.Errorf("Wrong issues for another file %v", rules)
+	}
+
+	for _, bad := range []string{
+		`[{"element": "艹"}]`,
+		`[{"rule": "format", "reason": "no file"}]`,
+		`[{"file": "[", "reason": "bad pattern"}]`,
+		`[{"file": "08475.svg", "rule": "no-such-rule", "reason": "test"}]`,
+	} {
+		write(bad)
+		if _, err := ReadSuppressions(file); err == nil {
+			t.Errorf("No error for %s", bad)
+		}
+	}
+}
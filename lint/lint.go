@@ -0,0 +1,298 @@
+/*
+   Package lint checks KanjiVG files for problems. Each check is a Rule,
+   which reports the problems it finds in a file as Issues. The
+   built-in rules are in a registry, so a Linter can run any selection
+   of them over one file or a whole corpus.
+*/
+
+package lint
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"kvg"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// How bad an issue is.
+type Severity int
+
+const (
+	// Something which may be worth looking at.
+	Info Severity = iota
+	// Something which is probably wrong.
+	Warning
+	// Something which is certainly wrong.
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// Get the severity called name, such as "warning".
+func ParseSeverity(name string) (s Severity, err error) {
+	for i, n := range severityNames {
+		if n == name {
+			return Severity(i), nil
+		}
+	}
+	return s, fmt.Errorf("unknown severity '%s'", name)
+}
+
+// A change which fixes an issue.
+type Fix struct {
+	// What the fix does, such as "remove the position".
+	Description string
+	// Make the change to svg, which is the file in which the issue was
+	// found, or a new copy of it. The elements are found again by
+	// their ids, so this does not depend on pointers into the SVG
+	// which was checked.
+	Apply func(svg *kvg.SVG) error
+}
+
+// The fixes of issues, in order, as edits for a kvg.Fixer. A fix
+// shared by more than one issue is only given once, and issues with no
+// fix are left out.
+func Edits(issues []Issue) (edits []kvg.Edit) {
+	seen := make(map[*Fix]bool)
+	for _, i := range issues {
+		if i.Fix == nil || seen[i.Fix] {
+			continue
+		}
+		seen[i.Fix] = true
+		edits = append(edits, kvg.Edit(*i.Fix))
+	}
+	return edits
+}
+
+// A problem found by a rule.
+type Issue struct {
+	// The file name, as in kvg.KanjiFile.Path.
+	File string
+	// The id of the element with the problem, or the empty string if
+	// the problem is with the file as a whole.
+	ID string
+	// The line and column in the file of the start tag of the element
+	// with the problem, or of the problem itself, counting from one,
+	// or zero if they are not known. The Linter fills these in from
+	// the source of the element with the id ID if the rule does not.
+	Line, Column int
+	// The line and column just after the end of the element, if the
+	// Linter found it from ID, otherwise zero.
+	EndLine, EndColumn int
+	// The name of the rule which found the problem.
+	Rule     string
+	Severity Severity
+	Message  string
+	// A fix for the problem, or nil if the rule does not know how to
+	// fix it.
+	Fix *Fix
+}
+
+func (i *Issue) String() string {
+	where := i.File
+	if len(i.ID) > 0 {
+		where += ": " + i.ID
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", where, i.Severity, i.Message, i.Rule)
+}
+
+// A check of KanjiVG files.
+type Rule interface {
+	// The name of the rule, such as "empty-path".
+	Name() string
+	// A one-line description of what the rule checks.
+	Doc() string
+	// Check one file, returning the issues found. The File and Rule
+	// of the issues are filled in by the Linter. Check must not alter
+	// kf. A rule may remember things between files, for example to
+	// compare the variants of a kanji, since a Linter checks one file
+	// at a time, in order of file name.
+	Check(kf *kvg.KanjiFile) []Issue
+}
+
+// The functions making each rule, by name.
+var registry = make(map[string]func() Rule)
+
+// Add the rule made by newRule to the registry. A new rule is made for
+// each Linter, so that rules which remember things between files do
+// not share them. Register panics if there is already a rule of the
+// same name.
+func Register(newRule func() Rule) {
+	name := newRule().Name()
+	if _, ok := registry[name]; ok {
+		panic("lint: rule " + name + " registered twice")
+	}
+	registry[name] = newRule
+}
+
+// The names of the rules in the registry, in alphabetical order.
+func Names() (names []string) {
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Make a new instance of the rule called name.
+func NewRule(name string) (r Rule, err error) {
+	newRule, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown rule '%s'", name)
+	}
+	return newRule(), nil
+}
+
+// The name of the pseudo-rule for files which cannot be read or
+// parsed.
+const ParseRule = "parse"
+
+// Runs a selection of rules.
+type Linter struct {
+	Rules []Rule
+	// Issues which match any of these are not reported.
+	Suppressions []Suppression
+	// If this is not nil, issues in it are not reported.
+	Baseline *Baseline
+	// The number of issues not reported because of Suppressions or
+	// Baseline.
+	Hidden int
+}
+
+// Should i, found in kf, which is nil if the file could not be read,
+// not be reported?
+func (l *Linter) hide(kf *kvg.KanjiFile, i *Issue) bool {
+	for n := range l.Suppressions {
+		if l.Suppressions[n].Matches(kf, i) {
+			l.Hidden++
+			return true
+		}
+	}
+	if l.Baseline != nil && l.Baseline.Known(i) {
+		l.Hidden++
+		return true
+	}
+	return false
+}
+
+// Make a Linter running the rules with names, or all the rules in the
+// registry if there are no names.
+func New(names ...string) (l *Linter, err error) {
+	if len(names) == 0 {
+		names = Names()
+	}
+	l = &Linter{}
+	for _, name := range names {
+		r, err := NewRule(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		l.Rules = append(l.Rules, r)
+	}
+	return l, nil
+}
+
+// Run the rules on kf. This must not be called from more than one
+// goroutine at once. The rules are not run on a file with no base
+// group, which is reported with the rule ParseRule. Issues which are
+// suppressed or in the baseline are left out.
+func (l *Linter) CheckFile(kf *kvg.KanjiFile) (issues []Issue) {
+	if !kf.SVG.HasBaseGroup() {
+		return l.parseIssues(kf, kf.Path, kvg.ErrNoBaseGroup)
+	}
+	for _, r := range l.Rules {
+		for _, i := range r.Check(kf) {
+			if len(i.File) == 0 {
+				i.File = kf.Path
+			}
+			if len(i.Rule) == 0 {
+				i.Rule = r.Name()
+			}
+			if i.Line == 0 {
+				span := locate(&kf.SVG, i.ID)
+				i.Line, i.Column = span.Line, span.Column
+				i.EndLine, i.EndColumn = span.EndLine, span.EndColumn
+			}
+			if l.hide(kf, &i) {
+				continue
+			}
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+// Read, parse and check the file called file. If it cannot be read or
+// parsed, the issue is reported with the rule ParseRule.
+func (l *Linter) CheckPath(file string) (issues []Issue) {
+	kf := &kvg.KanjiFile{Name: filepath.Base(file), Path: file}
+	var err error
+	kf.Contents, err = os.ReadFile(file)
+	if err == nil {
+		kf.SVG, err = kvg.ParseKanji(kf.Contents)
+	}
+	if err != nil {
+		return l.parseIssues(nil, file, err)
+	}
+	return l.CheckFile(kf)
+}
+
+// The issues for a file which could not be read or parsed, unless they
+// are hidden.
+func (l *Linter) parseIssues(kf *kvg.KanjiFile, file string, err error) []Issue {
+	i := parseIssue(file, err)
+	if l.hide(kf, &i) {
+		return nil
+	}
+	return []Issue{i}
+}
+
+// The issue for a file which could not be read or parsed.
+func parseIssue(file string, err error) Issue {
+	i := Issue{
+		File:     file,
+		Rule:     ParseRule,
+		Severity: Error,
+		Message:  err.Error(),
+	}
+	var se *xml.SyntaxError
+	if errors.As(err, &se) {
+		i.Line = se.Line
+	}
+	return i
+}
+
+// Run the rules on all the files of c, in order of file name, calling
+// report for each issue. Files which cannot be read or parsed are
+// reported with the rule ParseRule at the end. The error is from ctx,
+// or from listing the files of c.
+func (l *Linter) CheckCorpus(ctx context.Context, c *kvg.Corpus, report func(i *Issue)) (err error) {
+	err = c.Walk(ctx, &kvg.WalkOptions{Ordered: true}, func(kf *kvg.KanjiFile) error {
+		for _, i := range l.CheckFile(kf) {
+			report(&i)
+		}
+		return nil
+	})
+	var errs kvg.FileErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	for _, fe := range errs {
+		for _, i := range l.parseIssues(nil, fe.File, fe.Err) {
+			report(&i)
+		}
+	}
+	return nil
+}
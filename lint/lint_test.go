@@ -0,0 +1,179 @@
+package lint
+
+import (
+	"kvg"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Read the test file, changing each old string to the new one after
+// it.
+func readTest(t *testing.T, changes ...string) []byte {
+	contents, err := os.ReadFile("../t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(contents)
+	for i := 0; i+1 < len(changes); i += 2 {
+		if !strings.Contains(s, changes[i]) {
+			t.Fatalf("No %s in test file", changes[i])
+		}
+		s = strings.Replace(s, changes[i], changes[i+1], 1)
+	}
+	return []byte(s)
+}
+
+// Parse contents as the file called name.
+func lintFile(t *testing.T, name string, contents []byte) *kvg.KanjiFile {
+	svg, err := kvg.ParseKanji(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &kvg.KanjiFile{Name: name, Path: "t/" + name, Contents: contents, SVG: svg}
+}
+
+// Run all the rules on contents, as the file called name.
+func lintTest(t *testing.T, name string, contents []byte) []Issue {
+	kf := lintFile(t, name, contents)
+	l, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.CheckFile(kf)
+}
+
+// Apply the fixes of issues to a new copy of contents, and return the
+// result.
+func fixTest(t *testing.T, contents []byte, issues []Issue) *kvg.SVG {
+	svg, err := kvg.ParseKanji(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range issues {
+		if i.Fix == nil {
+			t.Fatalf("No fix for %s", i.String())
+		}
+	}
+	for _, e := range Edits(issues) {
+		err = e.Apply(&svg)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &svg
+}
+
+func TestRegistry(t *testing.T) {
+	names := Names()
+	for _, want := range []string{"bogus-group", "empty-path", "format", "missing-stroke", "radical"} {
+		found := false
+		for _, n := range names {
+			found = found || n == want
+		}
+		if !found {
+			t.Errorf("No rule %s in %v", want, names)
+		}
+	}
+	if _, err := New("empty-path", "no-such-rule"); err == nil {
+		t.Errorf("No error for unknown rule")
+	}
+	if s, err := ParseSeverity("warning"); err != nil || s != Warning || s.String() != "warning" {
+		t.Errorf("Wrong severity %s %v", s, err)
+	}
+}
+
+func TestRules(t *testing.T) {
+	clean := readTest(t)
+	if issues := lintTest(t, "08475.svg", clean); len(issues) != 0 {
+		t.Errorf("Issues in clean file: %v", issues)
+	}
+	tests := []struct {
+		name    string
+		changes []string
+		rule    string
+		id      string
+	}{
+		{"08475.svg", []string{`id="kvg:08475-s6" kvg:type="㇒"`, `id="kvg:08475-s6" kvg:type="Missing stroke"`},
+			"missing-stroke", "kvg:08475-s6"},
+		{"08475.svg", []string{`<g id="kvg:08475-g6" kvg:element="天" kvg:position="bottom">`,
+			`<g id="kvg:08475-g6" kvg:position="bottom"><g id="kvg:08475-g9">`,
+			`d="M38.31,58.69c1.51,0.59,3.54,0.56,5.09,0.35c6.61-0.91,13.19-1.64,19.48-2.05c1.79-0.12,3.62-0.08,5.39,0.34"/>`,
+			`d="M38.31,58.69c1.51,0.59,3.54,0.56,5.09,0.35c6.61-0.91,13.19-1.64,19.48-2.05c1.79-0.12,3.62-0.08,5.39,0.34"/></g>`},
+			"bogus-group", "kvg:08475-g9"},
+		{"08475.svg", []string{"<svg ", "<svg  "}, "format", ""},
+	}
+	for _, test := range tests {
+		contents := readTest(t, test.changes...)
+		var issues []Issue
+		for _, i := range lintTest(t, test.name, contents) {
+			// Changing the file by hand may change its formatting.
+			if i.Rule == test.rule || i.Rule != "format" {
+				issues = append(issues, i)
+			}
+		}
+		if len(issues) != 1 || issues[0].Rule != test.rule || issues[0].ID != test.id ||
+			issues[0].File != "t/"+test.name {
+			t.Errorf("%s: wrong issues %v", test.rule, issues)
+		} else if test.rule == "bogus-group" && issues[0].Message != "has one stroke and no element or position" {
+			t.Errorf("Wrong message %s", issues[0].Message)
+		}
+	}
+}
+
+func TestFixes(t *testing.T) {
+	// Each broken file is fixed by the fixes of its issues.
+	tests := []struct {
+		name    string
+		changes []string
+		rules   []string
+	}{
+		{"08475.svg", []string{`<g id="kvg:08475" kvg:element="葵">`,
+			`<g id="kvg:08475" kvg:element="葵" kvg:position="top">`}, []string{"base-position"}},
+		{"08475.svg", []string{"\t<text transform=\"matrix(1 0 0 1 63.25 82.25)\">12</text>\n", ""},
+			[]string{"stroke-numbers"}},
+		{"08475.svg", []string{`d="M38.31,58.69c1.51,0.59,3.54,0.56,5.09,0.35c6.61-0.91,13.19-1.64,19.48-2.05c1.79-0.12,3.62-0.08,5.39,0.34"`,
+			`d=""`}, []string{"empty-path"}},
+		{"08475.svg", []string{`d="M38.38,12.25c1.12,0.88,1.46,1.74,1.6,2.45c1.29,6.6,2.4,12.9,2.77,15.3"`, `d=""`,
+			`d="M28.48,38.17c1.32,0.5,2.59,0.56,4.7,0.17c5.19-0.96,7.19-1.34,11.91-2.58c3.42-0.9,4.21,0.68,3.09,3.37c-2.64,6.32-16.53,24.16-33.88,29.9"`, `d=""`},
+			[]string{"empty-path", "empty-path"}},
+		{"08476.svg", nil, []string{"base-element", "ids", "ids", "ids"}},
+	}
+	for _, test := range tests {
+		contents := readTest(t, test.changes...)
+		issues := lintTest(t, test.name, contents)
+		var rules []string
+		for _, i := range issues {
+			if i.Rule != "format" {
+				rules = append(rules, i.Rule)
+			}
+		}
+		if strings.Join(rules, ",") != strings.Join(test.rules, ",") {
+			t.Errorf("%s: wrong issues %v", test.name, issues)
+			continue
+		}
+		// The ids issues share one fix, and so do the empty paths.
+		if test.name == "08476.svg" && len(Edits(issues)) != 2 ||
+			rules[0] == "empty-path" && len(Edits(issues)) != 1 {
+			t.Errorf("Wrong edits %v", Edits(issues))
+		}
+		svg := fixTest(t, contents, issues)
+		if len(rules) == 2 && rules[0] == "empty-path" {
+			// The strokes after the empty ones are kept, and moved up.
+			paths := svg.GetPaths()
+			if len(paths) != 10 || !strings.HasPrefix(paths[1].D, "M67.39,10.25") ||
+				paths[1].ID != "kvg:08475-s2" || !strings.HasPrefix(paths[2].D, "M24.56,45.46") ||
+				paths[2].ID != "kvg:08475-s3" || svg.Groups[1].Label(paths[9]) == nil ||
+				svg.Groups[1].Label(&kvg.Path{ID: "kvg:08475-s11"}) != nil {
+				t.Errorf("Wrong paths or labels after removing the empty ones")
+			}
+		}
+		out, err := svg.MakeXML()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if left := lintTest(t, test.name, out); len(left) != 0 {
+			t.Errorf("%v: issues after fixing: %v", test.rules, left)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package lint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"kvg"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) (err error) {
+	*s, err = ParseSeverity(string(text))
+	return err
+}
+
+// The level of a SARIF result for each severity.
+var sarifLevels = []string{"note", "warning", "error"}
+
+// Find where the group or path with the id id was in the file which
+// svg was read from. If there is no such element, the span is not
+// valid.
+func locate(svg *kvg.SVG, id string) kvg.SourceSpan {
+	if len(id) == 0 {
+		return kvg.SourceSpan{}
+	}
+	if g := findGroup(svg, id); g != nil {
+		return g.Source()
+	}
+	if p := findPath(svg, id); p != nil {
+		return p.Source()
+	}
+	return kvg.SourceSpan{}
+}
+
+// The line and column of the byte offset in contents, counting from
+// one, with the column in characters.
+func position(contents []byte, offset int) (line, col int) {
+	before := contents[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(before, '\n') + 1
+	col = utf8.RuneCount(before[lineStart:]) + 1
+	return line, col
+}
+
+// The formats of a Writer.
+type OutputFormat int
+
+const (
+	// Lines as given by Issue.String.
+	OutputText OutputFormat = iota
+	// One JSON object per line.
+	OutputJSONLines
+	// One SARIF 2.1.0 log, written by Writer.Close.
+	OutputSARIF
+	// Lines of the form "file:line:col: severity: message [rule]", as
+	// understood by editors and by the problem matchers of GitHub
+	// Actions.
+	OutputCompact
+)
+
+var outputNames = map[string]OutputFormat{
+	"text":    OutputText,
+	"jsonl":   OutputJSONLines,
+	"sarif":   OutputSARIF,
+	"compact": OutputCompact,
+}
+
+// Get the output format called name, which is one of "text", "jsonl",
+// "sarif" or "compact".
+func ParseOutputFormat(name string) (f OutputFormat, err error) {
+	f, ok := outputNames[name]
+	if !ok {
+		return f, fmt.Errorf("unknown output format '%s'", name)
+	}
+	return f, nil
+}
+
+// An issue as written in JSON Lines. The end line and column, just
+// after the end of the element, are left out if they are not known.
+type jsonIssue struct {
+	File      string   `json:"file"`
+	Line      int      `json:"line,omitempty"`
+	Column    int      `json:"column,omitempty"`
+	EndLine   int      `json:"endLine,omitempty"`
+	EndColumn int      `json:"endColumn,omitempty"`
+	ID        string   `json:"id,omitempty"`
+	Rule      string   `json:"rule"`
+	Severity  Severity `json:"severity"`
+	Message   string   `json:"message"`
+	// The description of the fix, if there is one.
+	Fix string `json:"fix,omitempty"`
+}
+
+// Writes issues in one of the output formats, and keeps track of the
+// most severe.
+type Writer struct {
+	w      *bufio.Writer
+	format OutputFormat
+	// The issues, for SARIF, which is written all at once.
+	issues []Issue
+	count  int
+	worst  Severity
+}
+
+// Make a writer of issues to w in format.
+func NewWriter(w io.Writer, format OutputFormat) *Writer {
+	return &Writer{w: bufio.NewWriter(w), format: format}
+}
+
+// Write one issue.
+func (w *Writer) Write(i *Issue) (err error) {
+	if w.count == 0 || i.Severity > w.worst {
+		w.worst = i.Severity
+	}
+	w.count++
+	switch w.format {
+	case OutputSARIF:
+		w.issues = append(w.issues, *i)
+		return nil
+	case OutputJSONLines:
+		ji := jsonIssue{
+			File:      i.File,
+			Line:      i.Line,
+			Column:    i.Column,
+			EndLine:   i.EndLine,
+			EndColumn: i.EndColumn,
+			ID:        i.ID,
+			Rule:      i.Rule,
+			Severity:  i.Severity,
+			Message:   i.Message,
+		}
+		if i.Fix != nil {
+			ji.Fix = i.Fix.Description
+		}
+		b, err := json.Marshal(&ji)
+		if err != nil {
+			return err
+		}
+		_, err = w.w.Write(append(b, '\n'))
+		return err
+	case OutputCompact:
+		line, col := i.Line, i.Column
+		if line == 0 {
+			line, col = 1, 1
+		}
+		_, err = fmt.Fprintf(w.w, "%s:%d:%d: %s: %s [%s]\n",
+			i.File, line, col, i.Severity, i.Message, i.Rule)
+		return err
+	}
+	_, err = fmt.Fprintln(w.w, i.String())
+	return err
+}
+
+// Write out anything which is waiting to be written, which for SARIF
+// is the whole log. Call this at the end.
+func (w *Writer) Close() (err error) {
+	if w.format == OutputSARIF {
+		err = w.writeSARIF()
+		if err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// The number of issues written.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// The exit status for a command which has written the issues: 0 if
+// there were none, or only Info issues, 1 if the worst was a Warning,
+// and 2 if there was an Error.
+func (w *Writer) ExitStatus() int {
+	if w.count == 0 || w.worst < Warning {
+		return 0
+	}
+	if w.worst == Warning {
+		return 1
+	}
+	return 2
+}
+
+// The parts of a SARIF 2.1.0 log used by Writer.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool       sarifTool     `json:"tool"`
+		ColumnKind string        `json:"columnKind"`
+		Results    []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name  string      `json:"name"`
+		Rules []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		RuleIndex int             `json:"ruleIndex"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysical  `json:"physicalLocation"`
+		LogicalLocations []sarifLogical `json:"logicalLocations,omitempty"`
+	}
+	sarifPhysical struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           *sarifRegion  `json:"region,omitempty"`
+	}
+	sarifArtifact struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine   int `json:"startLine"`
+		StartColumn int `json:"startColumn,omitempty"`
+		EndLine     int `json:"endLine,omitempty"`
+		EndColumn   int `json:"endColumn,omitempty"`
+	}
+	sarifLogical struct {
+		Name string `json:"name"`
+		Kind string `json:"kind"`
+	}
+)
+
+// Write the issues as a SARIF log.
+func (w *Writer) writeSARIF() error {
+	run := sarifRun{
+		Tool:       sarifTool{sarifDriver{Name: "kvg-lint", Rules: []sarifRule{}}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	ruleIndex := make(map[string]int)
+	for _, i := range w.issues {
+		index, ok := ruleIndex[i.Rule]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[i.Rule] = index
+			doc := "Files which cannot be read or parsed"
+			if r, err := NewRule(i.Rule); err == nil {
+				doc = r.Doc()
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules,
+				sarifRule{ID: i.Rule, ShortDescription: sarifMessage{doc}})
+		}
+		loc := sarifLocation{
+			PhysicalLocation: sarifPhysical{
+				ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(i.File)},
+			},
+		}
+		if i.Line > 0 {
+			loc.PhysicalLocation.Region = &sarifRegion{
+				StartLine:   i.Line,
+				StartColumn: i.Column,
+				EndLine:     i.EndLine,
+				EndColumn:   i.EndColumn,
+			}
+		}
+		if len(i.ID) > 0 {
+			loc.LogicalLocations = []sarifLogical{{Name: i.ID, Kind: "element"}}
+		}
+		level := "error"
+		if i.Severity >= 0 && int(i.Severity) < len(sarifLevels) {
+			level = sarifLevels[i.Severity]
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    i.Rule,
+			RuleIndex: index,
+			Level:     level,
+			Message:   sarifMessage{i.Message},
+			Locations: []sarifLocation{loc},
+		})
+	}
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	enc := json.NewEncoder(w.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&log)
+}
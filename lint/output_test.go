@@ -0,0 +1,94 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"kvg"
+	"strings"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	contents := readTest(t,
+		`id="kvg:08475-s6" kvg:type="㇒"`, `id="kvg:08475-s6" kvg:type="Missing stroke"`,
+		`<g id="kvg:08475" kvg:element="葵">`, `<g id="kvg:08475" kvg:element="葵" kvg:position="top">`)
+	issues := lintTest(t, "08475.svg", contents)
+	if len(issues) != 2 {
+		t.Fatalf("Wrong issues %v", issues)
+	}
+	// The path is on line 52 after four tabs.
+	var stroke *Issue
+	for i := range issues {
+		if issues[i].Rule == "missing-stroke" {
+			stroke = &issues[i]
+		}
+	}
+	if stroke == nil || stroke.Line != 52 || stroke.Column != 5 || stroke.EndLine != 52 {
+		t.Fatalf("Wrong position of issue %+v", stroke)
+	}
+
+	write := func(format OutputFormat) (string, *Writer) {
+		var out bytes.Buffer
+		w := NewWriter(&out, format)
+		for i := range issues {
+			if err := w.Write(&issues[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String(), w
+	}
+
+	out, w := write(OutputCompact)
+	if !strings.Contains(out, "t/08475.svg:52:5: warning: missing stroke [missing-stroke]\n") {
+		t.Errorf("Wrong compact output:\n%s", out)
+	}
+	if w.Count() != 2 || w.ExitStatus() != 1 {
+		t.Errorf("Wrong count %d or exit status %d", w.Count(), w.ExitStatus())
+	}
+
+	out, _ = write(OutputJSONLines)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Wrong JSON Lines:\n%s", out)
+	}
+	for _, line := range lines {
+		var ji jsonIssue
+		if err := json.Unmarshal([]byte(line), &ji); err != nil {
+			t.Fatal(err)
+		}
+		// The base group goes from line 39 to the end of line 66.
+		if ji.Rule == "base-position" && (ji.Severity != Warning || ji.Fix != "remove the position" ||
+			ji.Line != 39 || ji.EndLine != 66 || ji.EndColumn != 5) {
+			t.Errorf("Wrong JSON issue %s", line)
+		}
+	}
+
+	out, _ = write(OutputSARIF)
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 ||
+		len(log.Runs[0].Tool.Driver.Rules) != 2 {
+		t.Fatalf("Wrong SARIF:\n%s", out)
+	}
+	for _, r := range log.Runs[0].Results {
+		rule := log.Runs[0].Tool.Driver.Rules[r.RuleIndex]
+		region := r.Locations[0].PhysicalLocation.Region
+		if rule.ID != r.RuleID || r.Level != "warning" || region == nil || region.StartLine == 0 ||
+			region.EndLine < region.StartLine || region.EndColumn == 0 {
+			t.Errorf("Wrong SARIF result %+v", r)
+		}
+	}
+
+	if _, w = write(OutputText); w.ExitStatus() != 1 {
+		t.Errorf("Wrong exit status %d", w.ExitStatus())
+	}
+	issues = append(issues, parseIssue("x.svg", kvg.ErrNoBaseGroup))
+	if _, w = write(OutputText); w.ExitStatus() != 2 {
+		t.Errorf("Wrong exit status %d for an error", w.ExitStatus())
+	}
+}
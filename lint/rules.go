@@ -0,0 +1,487 @@
+package lint
+
+import (
+	"bytes"
+	"fmt"
+	"kvg"
+)
+
+// The built-in rules, ported from the checking commands in cmd.
+func init() {
+	Register(func() Rule {
+		return &funcRule{"bogus-group",
+			"Groups with at most one stroke and no element, position, radical or phon",
+			bogusGroup}
+	})
+	Register(func() Rule {
+		return &funcRule{"stroke-numbers",
+			"The number of stroke number labels differs from the number of strokes",
+			strokeNumbers}
+	})
+	Register(func() Rule {
+		return &funcRule{"empty-path", "Paths with no d attribute", emptyPath}
+	})
+	Register(func() Rule {
+		return &funcRule{"missing-stroke", "Paths of the type \"Missing stroke\"", missingStroke}
+	})
+	Register(func() Rule {
+		return &funcRule{"base-element",
+			"The element of the base group differs from the kanji of the file name",
+			baseElement}
+	})
+	Register(func() Rule {
+		return &funcRule{"ids", "The ids of the top groups differ from the file name", ids}
+	})
+	Register(func() Rule {
+		return &funcRule{"base-position", "The base group has a position", basePosition}
+	})
+	Register(func() Rule {
+		return &funcRule{"missing-position",
+			"Parts of the kanji, the groups in the base group, with no position",
+			missingPosition}
+	})
+	Register(func() Rule {
+		return &radicalRule{kanjiRad: make(map[rune]map[string]string)}
+	})
+	Register(func() Rule {
+		return &funcRule{"format",
+			"The file differs from the standard formatting of the KanjiVG files",
+			format}
+	})
+}
+
+// A rule which is just a function.
+type funcRule struct {
+	name  string
+	doc   string
+	check func(kf *kvg.KanjiFile) []Issue
+}
+
+func (r *funcRule) Name() string {
+	return r.name
+}
+
+func (r *funcRule) Doc() string {
+	return r.doc
+}
+
+func (r *funcRule) Check(kf *kvg.KanjiFile) []Issue {
+	return r.check(kf)
+}
+
+// The path of svg with the id id, or nil.
+func findPath(svg *kvg.SVG, id string) *kvg.Path {
+	for _, p := range svg.GetPaths() {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// The group of svg with the id id, or nil.
+func findGroup(svg *kvg.SVG, id string) *kvg.Group {
+	for i := range svg.Groups {
+		for _, g := range svg.Groups[i].GetGroups() {
+			if g.ID == id {
+				return g
+			}
+		}
+	}
+	return nil
+}
+
+// The index of the child of g which is c, or -1.
+func childIndex(g *kvg.Group, c *kvg.Child) int {
+	for i := range g.Children {
+		if &g.Children[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find groups which do nothing, with at most one stroke in them and
+// none of the attributes which would give a reason for the group.
+func bogusGroup(kf *kvg.KanjiFile) (issues []Issue) {
+	base := kf.SVG.BaseGroup()
+	for _, group := range base.GetGroups() {
+		if len(group.Element) > 0 || len(group.Position) > 0 ||
+			len(group.Radical) > 0 || len(group.Phon) > 0 {
+			continue
+		}
+		n := len(group.GetPaths())
+		if n > 1 {
+			continue
+		}
+		strokes := "no strokes"
+		if n == 1 {
+			strokes = "one stroke"
+		}
+		issues = append(issues, Issue{
+			ID:       group.ID,
+			Severity: Warning,
+			Message:  "has " + strokes + " and no element or position",
+		})
+	}
+	return issues
+}
+
+// Check that there is one stroke number label for each stroke.
+func strokeNumbers(kf *kvg.KanjiFile) (issues []Issue) {
+	svg := &kf.SVG
+	paths := svg.GetPaths()
+	remake := &Fix{
+		Description: "make the stroke numbers again",
+		Apply: func(svg *kvg.SVG) error {
+			return svg.MakeLabels(nil)
+		},
+	}
+	if len(svg.Groups) < 2 {
+		return []Issue{{
+			Severity: Warning,
+			Message:  "no stroke numbers",
+			Fix:      remake,
+		}}
+	}
+	nums := &svg.Groups[1]
+	nc := 0
+	for _, c := range nums.Children {
+		if c.IsText {
+			nc++
+		}
+	}
+	switch {
+	case nc < len(paths):
+		issues = append(issues, Issue{
+			ID:       nums.ID,
+			Severity: Error,
+			Message:  fmt.Sprintf("missing %d stroke numbers", len(paths)-nc),
+			Fix:      remake,
+		})
+	case nc > len(paths):
+		np := len(paths)
+		issues = append(issues, Issue{
+			ID:       nums.ID,
+			Severity: Error,
+			Message:  fmt.Sprintf("too many stroke numbers %d > %d", nc, np),
+			Fix: &Fix{
+				Description: "remove the extra stroke numbers",
+				Apply: func(svg *kvg.SVG) error {
+					nums := &svg.Groups[1]
+					n := 0
+					for i := 0; i < len(nums.Children); i++ {
+						if !nums.Children[i].IsText {
+							continue
+						}
+						n++
+						if n > np {
+							nums.RemoveChild(i)
+							i--
+						}
+					}
+					return nil
+				},
+			},
+		})
+	}
+	return issues
+}
+
+// Find paths with no 'd' attribute. The issues share one fix, since
+// removing a path renumbers the ones after it.
+func emptyPath(kf *kvg.KanjiFile) (issues []Issue) {
+	remove := &Fix{
+		Description: "remove the empty paths and their stroke numbers, and renumber",
+		Apply:       removeEmptyPaths,
+	}
+	for _, p := range kf.SVG.GetPaths() {
+		if len(p.D) > 0 {
+			continue
+		}
+		issues = append(issues, Issue{
+			ID:       p.ID,
+			Severity: Error,
+			Message:  "empty path",
+			Fix:      remove,
+		})
+	}
+	return issues
+}
+
+// Remove the paths with no 'd' attribute from svg, along with their
+// stroke number labels, and renumber what is left. The paths are
+// removed from the last to the first, so that removing one does not
+// move the ones still to be removed, and the ids, from which the
+// labels are found, are only renumbered at the end.
+func removeEmptyPaths(svg *kvg.SVG) error {
+	paths := svg.GetPaths()
+	for n := len(paths) - 1; n >= 0; n-- {
+		p := paths[n]
+		if len(p.D) > 0 {
+			continue
+		}
+		if p.Parent == nil || p.Parent.Parent == nil {
+			return fmt.Errorf("path %s has no parent", p.ID)
+		}
+		if len(svg.Groups) > 1 {
+			labels := &svg.Groups[1]
+			if t := labels.Label(p); t != nil && t.Parent != nil {
+				if i := childIndex(labels, t.Parent); i >= 0 {
+					labels.RemoveChild(i)
+				}
+			}
+		}
+		g := p.Parent.Parent
+		g.RemoveChild(childIndex(g, p.Parent))
+	}
+	svg.RenumberXML()
+	return nil
+}
+
+// Find strokes marked as missing.
+func missingStroke(kf *kvg.KanjiFile) (issues []Issue) {
+	for _, p := range kf.SVG.GetPaths() {
+		if p.Type == "Missing stroke" {
+			issues = append(issues, Issue{
+				ID:       p.ID,
+				Severity: Warning,
+				Message:  "missing stroke",
+			})
+		}
+	}
+	return issues
+}
+
+// Check that the element of the base group is the kanji of the file.
+func baseElement(kf *kvg.KanjiFile) (issues []Issue) {
+	_, kanji, _ := kvg.FileToParts(kf.Name)
+	base := kf.SVG.BaseGroup()
+	if kanji == 0 || len(base.Element) == 0 {
+		return nil
+	}
+	element := []rune(base.Element)[0]
+	if int64(element) == kanji {
+		return nil
+	}
+	want := string(rune(kanji))
+	return []Issue{{
+		ID:       base.ID,
+		Severity: Error,
+		Message: fmt.Sprintf("file name, %s [%05x], disagrees with element %s [%05x]",
+			want, kanji, base.Element, element),
+		Fix: &Fix{
+			Description: "set the element to " + want,
+			Apply: func(svg *kvg.SVG) error {
+				svg.BaseGroup().Element = want
+				return nil
+			},
+		},
+	}}
+}
+
+// Check that the ids of the StrokePaths, StrokeNumbers and base groups
+// go with the file name.
+func ids(kf *kvg.KanjiFile) (issues []Issue) {
+	id, _, _ := kvg.FileToParts(kf.Name)
+	svg := &kf.SVG
+	if len(id) == 0 {
+		return nil
+	}
+	rebase := &Fix{
+		Description: "set the base to kvg:" + id,
+		Apply: func(svg *kvg.SVG) error {
+			return svg.SetBase("kvg:" + id)
+		},
+	}
+	add := func(got, want string) {
+		if got == want {
+			return
+		}
+		issues = append(issues, Issue{
+			ID:       got,
+			Severity: Error,
+			Message:  fmt.Sprintf("id %s should be %s", got, want),
+			Fix:      rebase,
+		})
+	}
+	add(svg.Groups[0].ID, "kvg:StrokePaths_"+id)
+	if len(svg.Groups) > 1 {
+		add(svg.Groups[1].ID, "kvg:StrokeNumbers_"+id)
+	}
+	add(svg.BaseGroup().ID, "kvg:"+id)
+	return issues
+}
+
+// Check that the base group has no position.
+func basePosition(kf *kvg.KanjiFile) (issues []Issue) {
+	base := kf.SVG.BaseGroup()
+	if len(base.Position) == 0 {
+		return nil
+	}
+	return []Issue{{
+		ID:       base.ID,
+		Severity: Warning,
+		Message:  fmt.Sprintf("base group has silly position %s", base.Position),
+		Fix: &Fix{
+			Description: "remove the position",
+			Apply: func(svg *kvg.SVG) error {
+				svg.BaseGroup().Position = ""
+				return nil
+			},
+		},
+	}}
+}
+
+// Find the groups directly within the base group which have an element
+// but no position, so that how the parts of the kanji are arranged is
+// not known. Many kanji legitimately have such parts, so these are
+// only information.
+func missingPosition(kf *kvg.KanjiFile) (issues []Issue) {
+	base := kf.SVG.BaseGroup()
+	parts := 0
+	for _, c := range base.Children {
+		if !c.IsOther && !c.IsText {
+			parts++
+		}
+	}
+	if parts < 2 {
+		return nil
+	}
+	for i := range base.Children {
+		c := &base.Children[i]
+		g := &c.Group
+		if !c.IsGroup || len(g.Element) == 0 || len(g.Position) > 0 {
+			continue
+		}
+		issues = append(issues, Issue{
+			ID:       g.ID,
+			Severity: Info,
+			Message:  fmt.Sprintf("part %s has no position", g.Element),
+		})
+	}
+	return issues
+}
+
+// Checks that the radicals are present and consistent, and the same
+// in all the variants of a kanji.
+type radicalRule struct {
+	// The element of each type of radical of each kanji, from the
+	// first file of the kanji with that type of radical.
+	kanjiRad map[rune]map[string]string
+}
+
+func (r *radicalRule) Name() string {
+	return "radical"
+}
+
+func (r *radicalRule) Doc() string {
+	return "Radicals which are missing, inconsistent, or differ between the variants of a kanji"
+}
+
+func (r *radicalRule) Check(kf *kvg.KanjiFile) (issues []Issue) {
+	_, num, _ := kvg.FileToParts(kf.Name)
+	kanji := rune(num)
+	if !kvg.ExpectRadical(kanji) {
+		return nil
+	}
+	var rad kvg.Radical
+	base := kf.SVG.BaseGroup()
+	base.SearchRadical(&rad)
+	if len(rad.General) == 0 && len(rad.Tradit) == 0 &&
+		len(rad.Nelson) == 0 && len(rad.JIS) == 0 {
+		issues = append(issues, Issue{
+			Severity: Warning,
+			Message:  "no radical found",
+		})
+	}
+	if len(rad.Nelson) > 0 && len(rad.Tradit) == 0 {
+		issues = append(issues, Issue{
+			ID:       rad.Nelson[0].ID,
+			Severity: Warning,
+			Message:  "inconsistent radicals: Nelson, no Tradit",
+		})
+	}
+	issues = append(issues, r.same(kanji, "general", rad.General)...)
+	issues = append(issues, r.same(kanji, "nelson", rad.Nelson)...)
+	issues = append(issues, r.same(kanji, "tradit", rad.Tradit)...)
+	issues = append(issues, r.same(kanji, "jis", rad.JIS)...)
+	return issues
+}
+
+// Check that the radical groups gs of the type what are the same as
+// that type of radical in the other variants of kanji.
+func (r *radicalRule) same(kanji rune, what string, gs []*kvg.Group) (issues []Issue) {
+	if len(gs) == 0 {
+		return nil
+	}
+	if r.kanjiRad[kanji] == nil {
+		r.kanjiRad[kanji] = make(map[string]string)
+	}
+	gen := r.kanjiRad[kanji][what]
+	if len(gen) == 0 {
+		r.kanjiRad[kanji][what] = gs[0].El()
+		return nil
+	}
+	for _, g := range gs {
+		el := g.El()
+		if el != gen {
+			issues = append(issues, Issue{
+				ID:       g.ID,
+				Severity: Warning,
+				Message: fmt.Sprintf("%s radical does not match other variant files '%s' != '%s'",
+					what, el, gen),
+			})
+		}
+	}
+	return issues
+}
+
+// Check that the file is written in the standard format, by reading
+// it again and writing it out with the formatting cleared.
+func format(kf *kvg.KanjiFile) (issues []Issue) {
+	svg, err := kvg.ParseKanji(kf.Contents)
+	if err != nil {
+		return nil
+	}
+	svg.ClearFormat()
+	out, err := svg.MakeXML()
+	if err != nil {
+		return []Issue{{Severity: Error, Message: err.Error()}}
+	}
+	in := kf.Contents
+	if bytes.Equal(in, out) {
+		return nil
+	}
+	n := len(in)
+	if len(out) < n {
+		n = len(out)
+	}
+	i := 0
+	for i < n && in[i] == out[i] {
+		i++
+	}
+	line, col := position(in, i)
+	what := "attribute or other"
+	if i < n && (white(in[i]) || white(out[i])) {
+		what = "whitespace"
+	}
+	return []Issue{{
+		Line:     line,
+		Column:   col,
+		Severity: Warning,
+		Message:  what + " difference from the standard formatting",
+		Fix: &Fix{
+			Description: "write the file in the standard format",
+			Apply: func(svg *kvg.SVG) error {
+				svg.ClearFormat()
+				return nil
+			},
+		},
+	}}
+}
+
+// True if c is whitespace.
+func white(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
@@ -0,0 +1,173 @@
+package kvg
+
+import (
+	"math"
+	"strings"
+)
+
+// Normalize returns a copy of the path which only uses the absolute
+// commands M, L, C, Q and Z. The shorthands H and V become L, S
+// becomes C and T becomes Q, with their first control points
+// reflected from the previous command, and arcs become cubic Béziers.
+// Arcs with a zero radius become lines, as the SVG specification says,
+// and arcs which end where they start are left out.
+func (p SVGPath) Normalize() (n SVGPath) {
+	abs := p.convert(true)
+	var cx, cy, sx, sy float64
+	// The last control point of the previous command, and what kind of
+	// curve the previous command was, for reflecting.
+	var px, py float64
+	var prev byte
+	for _, subpath := range abs.Subpaths {
+		var commands []Command
+		for _, command := range subpath.Commands {
+			a := command.Params
+			kind := byte(0)
+			switch command.Symbol {
+			case "M":
+				commands = append(commands, command)
+				sx, sy = a[0], a[1]
+			case "Z":
+				commands = append(commands, command)
+				cx, cy = sx, sy
+				prev = 0
+				continue
+			case "L":
+				commands = append(commands, command)
+			case "H":
+				a = []float64{a[0], cy}
+				commands = append(commands, Command{"L", a})
+			case "V":
+				a = []float64{cx, a[0]}
+				commands = append(commands, Command{"L", a})
+			case "C":
+				commands = append(commands, command)
+				px, py, kind = a[2], a[3], 'C'
+			case "S":
+				x1, y1 := cx, cy
+				if prev == 'C' {
+					x1, y1 = 2*cx-px, 2*cy-py
+				}
+				a = []float64{x1, y1, a[0], a[1], a[2], a[3]}
+				commands = append(commands, Command{"C", a})
+				px, py, kind = a[2], a[3], 'C'
+			case "Q":
+				commands = append(commands, command)
+				px, py, kind = a[0], a[1], 'Q'
+			case "T":
+				x1, y1 := cx, cy
+				if prev == 'Q' {
+					x1, y1 = 2*cx-px, 2*cy-py
+				}
+				a = []float64{x1, y1, a[0], a[1]}
+				commands = append(commands, Command{"Q", a})
+				px, py, kind = x1, y1, 'Q'
+			case "A":
+				commands = append(commands, arcToCubics(cx, cy, a)...)
+			}
+			cx, cy = a[len(a)-2], a[len(a)-1]
+			prev = kind
+		}
+		n.Subpaths = append(n.Subpaths, Subpath{commands})
+	}
+	return n
+}
+
+// arcToCubics converts the absolute arc with parameters a, starting at
+// (x1, y1), into cubic Béziers, using the conversion from endpoint to
+// center parameterization in the implementation notes of the SVG
+// specification.
+func arcToCubics(x1, y1 float64, a []float64) (commands []Command) {
+	rx, ry := math.Abs(a[0]), math.Abs(a[1])
+	phi := a[2] * math.Pi / 180
+	largeArc, sweep := a[3] != 0, a[4] != 0
+	x2, y2 := a[5], a[6]
+	if x1 == x2 && y1 == y2 {
+		return nil
+	}
+	if rx == 0 || ry == 0 {
+		return []Command{{"L", []float64{x2, y2}}}
+	}
+	sin, cos := math.Sincos(phi)
+	// Step 1: the start point in the coordinates of the ellipse.
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p := cos*dx + sin*dy
+	y1p := -sin*dx + cos*dy
+	// Make the radii big enough to reach the end point.
+	lambda := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry)
+	if lambda > 1 {
+		s := math.Sqrt(lambda)
+		rx *= s
+		ry *= s
+	}
+	// Step 2: the center in the coordinates of the ellipse.
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	coef := 0.0
+	if num > 0 && den > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if largeArc == sweep {
+		coef = -coef
+	}
+	cxp := coef * rx * y1p / ry
+	cyp := -coef * ry * x1p / rx
+	// Step 3: the center.
+	cx := cos*cxp - sin*cyp + (x1+x2)/2
+	cy := sin*cxp + cos*cyp + (y1+y2)/2
+	// Step 4: the start angle and the angle the arc goes through.
+	theta := math.Atan2((y1p-cyp)/ry, (x1p-cxp)/rx)
+	end := math.Atan2((-y1p-cyp)/ry, (-x1p-cxp)/rx)
+	delta := end - theta
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+	// Split the arc into pieces of no more than a quarter turn, each
+	// of which is close to a cubic Bézier.
+	pieces := int(math.Ceil(math.Abs(delta)/(math.Pi/2) - 1e-9))
+	if pieces < 1 {
+		pieces = 1
+	}
+	step := delta / float64(pieces)
+	k := 4.0 / 3.0 * math.Tan(step/4)
+	// The point at angle t on the ellipse, and its derivative.
+	point := func(t float64) (x, y, dx, dy float64) {
+		st, ct := math.Sincos(t)
+		x = cx + rx*ct*cos - ry*st*sin
+		y = cy + rx*ct*sin + ry*st*cos
+		dx = -rx*st*cos - ry*ct*sin
+		dy = -rx*st*sin + ry*ct*cos
+		return x, y, dx, dy
+	}
+	for i := 0; i < pieces; i++ {
+		t1 := theta + float64(i)*step
+		t2 := t1 + step
+		ax, ay, adx, ady := point(t1)
+		bx, by, bdx, bdy := point(t2)
+		if i == pieces-1 {
+			// Finish exactly on the end point.
+			bx, by = x2, y2
+		}
+		commands = append(commands, Command{"C", []float64{
+			ax + k*adx, ay + k*ady,
+			bx - k*bdx, by - k*bdy,
+			bx, by,
+		}})
+	}
+	return commands
+}
+
+// IsNormal returns true if the path only uses the commands given by
+// Normalize.
+func (p SVGPath) IsNormal() bool {
+	for _, subpath := range p.Subpaths {
+		for _, command := range subpath.Commands {
+			if !strings.Contains("MLCQZ", command.Symbol) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,461 @@
+package kvg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Reads the elements of a KanjiVG file from d. If src is not nil, it
+// holds the input which d reads, and the text of each tag and the text
+// between tags is recorded in the Format of each element. Otherwise,
+// for example when called from xml.Unmarshal, only the attributes and
+// the unknown elements are kept.
+type parser struct {
+	d   *xml.Decoder
+	src *source
+}
+
+// The input of a parser. The first byte of buf is at offset base in
+// the input, so that a reader of a large file can throw away what it
+// has finished with.
+type source struct {
+	buf  []byte
+	base int64
+	// The line and column at the offset at, which only goes forwards.
+	at        int64
+	line, col int
+}
+
+// The line and column at offset, counting from one. The offsets asked
+// for must not go backwards.
+func (s *source) lineCol(offset int64) (line, col int) {
+	if s.line == 0 {
+		s.line, s.col = 1, 1
+	}
+	for ; s.at < offset; s.at++ {
+		switch c := s.buf[s.at-s.base]; {
+		case c == '\n':
+			s.line++
+			s.col = 1
+		case utf8.RuneStart(c):
+			s.col++
+		}
+	}
+	return s.line, s.col
+}
+
+// The input from offset begin to offset end.
+func (s *source) text(begin, end int64) string {
+	return string(s.buf[begin-s.base : end-s.base])
+}
+
+// The input from offset begin to the end of what has been read.
+func (s *source) rest(begin int64) string {
+	return string(s.buf[begin-s.base:])
+}
+
+// A source which keeps everything read from r.
+type recorder struct {
+	r io.Reader
+	source
+}
+
+func (rc *recorder) Read(p []byte) (n int, err error) {
+	n, err = rc.r.Read(p)
+	rc.buf = append(rc.buf, p[:n]...)
+	return n, err
+}
+
+// Forget the input before offset.
+func (rc *recorder) discard(offset int64) {
+	// Count the lines up to offset first.
+	rc.lineCol(offset)
+	n := copy(rc.buf, rc.buf[offset-rc.base:])
+	rc.buf = rc.buf[:n]
+	rc.base = offset
+}
+
+// Put the attributes in the KanjiVG namespace into KVGNamespace, if
+// they use the "kvg" prefix without declaring it, as the KanjiVG files
+// do. This returns a copy of attrs.
+func kvgAttrs(attrs []xml.Attr) []xml.Attr {
+	attrs = append([]xml.Attr(nil), attrs...)
+	for i := range attrs {
+		if attrs[i].Name.Space == "kvg" {
+			attrs[i].Name.Space = KVGNamespace
+		}
+	}
+	return attrs
+}
+
+// Is n the name of the SVG element called local, either in the SVG
+// namespace or in no namespace?
+func isSVG(n xml.Name, local string) bool {
+	return n.Local == local && (n.Space == "" || n.Space == SVGNamespace)
+}
+
+// Get the next token and, if the source is available, the text it was
+// read from.
+func (ps *parser) token() (tok xml.Token, raw string, err error) {
+	start := ps.d.InputOffset()
+	tok, err = ps.d.Token()
+	if err != nil {
+		return nil, "", err
+	}
+	if ps.src != nil {
+		raw = ps.src.text(start, ps.d.InputOffset())
+	}
+	return tok, raw, nil
+}
+
+// Read the rest of the element started by start, which was in the
+// source as tag, and return all of it as text.
+func (ps *parser) other(start xml.StartElement, tag string) (raw string, err error) {
+	if ps.src != nil {
+		begin := ps.d.InputOffset() - int64(len(tag))
+		err = ps.d.Skip()
+		if err != nil {
+			return "", err
+		}
+		return ps.src.text(begin, ps.d.InputOffset()), nil
+	}
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	err = e.EncodeToken(start)
+	if err != nil {
+		return "", err
+	}
+	for depth := 1; depth > 0; {
+		tok, err := ps.d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		err = e.EncodeToken(xml.CopyToken(tok))
+		if err != nil {
+			return "", err
+		}
+	}
+	err = e.Flush()
+	return buf.String(), err
+}
+
+// The text of a token which is not an element, for when the source is
+// not available.
+func tokenText(tok xml.Token) string {
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	e.EncodeToken(xml.CopyToken(tok))
+	e.Flush()
+	return buf.String()
+}
+
+// Collects the contents of an element which are not its children, for
+// Format.Space.
+type spacer struct {
+	record bool
+	space  []string
+	text   strings.Builder
+}
+
+// Add the text of a token to the current space.
+func (sp *spacer) add(tok xml.Token, raw string) {
+	if !sp.record {
+		return
+	}
+	if len(raw) == 0 {
+		raw = tokenText(tok)
+	}
+	sp.text.WriteString(raw)
+}
+
+// Finish the current space, when a child or the end tag is reached.
+func (sp *spacer) next() {
+	if !sp.record {
+		return
+	}
+	sp.space = append(sp.space, sp.text.String())
+	sp.text.Reset()
+}
+
+// Read the contents of an element up to its end. The function child
+// is called for each start element within it, and returns true if it
+// made the element into a child, or false and the text of the element
+// if it should be kept in f.Space. The function other, if not nil, is
+// called for other tokens, and returns true if it made the token into
+// a child. Otherwise the token is kept in f.Space.
+func (ps *parser) contents(start xml.StartElement, tag string, f *Format,
+	child func(el xml.StartElement, raw string) (isChild bool, text string, err error),
+	other func(tok xml.Token, raw string) (isChild bool)) (err error) {
+	sp := spacer{record: ps.src != nil}
+	var span SourceSpan
+	if sp.record {
+		f.Tag = tag
+		f.Attrs = append([]xml.Attr(nil), start.Attr...)
+		span.Offset = ps.d.InputOffset() - int64(len(tag))
+		span.Line, span.Column = ps.src.lineCol(span.Offset)
+	}
+	for {
+		tok, raw, err := ps.token()
+		if err != nil {
+			return err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			isChild, text, err := child(el, raw)
+			if err != nil {
+				return err
+			}
+			if isChild {
+				sp.next()
+			} else if sp.record {
+				sp.text.WriteString(text)
+			}
+		case xml.EndElement:
+			if el.Name != start.Name {
+				return fmt.Errorf("unexpected end element %s", el.Name.Local)
+			}
+			sp.next()
+			if sp.record {
+				f.Space = sp.space
+				f.End = raw
+				span.End = ps.d.InputOffset()
+				span.EndLine, span.EndColumn = ps.src.lineCol(span.End)
+				f.Span = span
+			}
+			return nil
+		default:
+			if other != nil && other(tok, raw) {
+				sp.next()
+				continue
+			}
+			sp.add(tok, raw)
+		}
+	}
+}
+
+// An element which is kept as text in the space.
+func (ps *parser) spaceElement(el xml.StartElement, raw string) (isChild bool, text string, err error) {
+	text, err = ps.other(el, raw)
+	return false, text, err
+}
+
+// Read a path element.
+func (ps *parser) path(p *Path, start xml.StartElement, tag string) (err error) {
+	p.XMLName = start.Name
+	start.Attr = kvgAttrs(start.Attr)
+	for _, attr := range start.Attr {
+		switch attr.Name {
+		case xml.Name{Local: "d"}:
+			p.D = attr.Value
+		case xml.Name{Local: "id"}:
+			p.ID = attr.Value
+		case xml.Name{Space: KVGNamespace, Local: "type"}:
+			p.Type = attr.Value
+		case xml.Name{Local: "class"}:
+			p.Class = attr.Value
+		default:
+			p.Extra = append(p.Extra, attr)
+		}
+	}
+	/* There is no content in the paths, but if there is, it is kept
+	   in the Format as it is. */
+	return ps.contents(start, tag, &p.Format, ps.spaceElement, nil)
+}
+
+// Read a text element. Its character data goes into Content, and
+// everything within it, including any elements, is also kept in the
+// Format.
+func (ps *parser) text(t *Text, start xml.StartElement, tag string) (err error) {
+	t.XMLName = start.Name
+	start.Attr = kvgAttrs(start.Attr)
+	for _, attr := range start.Attr {
+		switch attr.Name {
+		case xml.Name{Local: "transform"}:
+			t.Transform = attr.Value
+		case xml.Name{Local: "class"}:
+			t.Class = attr.Value
+		default:
+			t.Extra = append(t.Extra, attr)
+		}
+	}
+	var content bytes.Buffer
+	err = ps.contents(start, tag, &t.Format, ps.spaceElement, func(tok xml.Token, raw string) bool {
+		if cd, ok := tok.(xml.CharData); ok {
+			content.Write(cd)
+		}
+		return false
+	})
+	t.Content = content.Bytes()
+	return err
+}
+
+// Read a group element.
+func (ps *parser) group(g *Group, start xml.StartElement, tag string) (err error) {
+	g.XMLName = start.Name
+	start.Attr = kvgAttrs(start.Attr)
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "" {
+			switch attr.Name.Local {
+			case "id":
+				g.ID = attr.Value
+				continue
+			case "style":
+				g.Style = attr.Value
+				continue
+			}
+		}
+		if attr.Name.Space != KVGNamespace {
+			g.Extra = append(g.Extra, attr)
+			continue
+		}
+		switch attr.Name.Local {
+		case "element":
+			g.Element = attr.Value
+		case "number":
+			g.Number = attr.Value
+		case "original":
+			g.Original = attr.Value
+		case "part":
+			g.Part = attr.Value
+		case "partial":
+			if attr.Value == "true" {
+				g.Partial = true
+			}
+		case "phon":
+			g.Phon = attr.Value
+		case "position":
+			g.Position = attr.Value
+		case "radical":
+			g.Radical = attr.Value
+		case "radicalForm":
+			g.RadicalForm = attr.Value
+		case "tradForm":
+			g.TradForm = attr.Value
+		case "variant":
+			if attr.Value == "true" {
+				g.Variant = true
+			}
+		default:
+			g.Extra = append(g.Extra, attr)
+		}
+	}
+	child := func(el xml.StartElement, raw string) (isChild bool, text string, err error) {
+		var c Child
+		c.Parent = g
+		switch {
+		case isSVG(el.Name, "g"):
+			err = ps.group(&c.Group, el, raw)
+			c.IsGroup = true
+			c.Group.Parent = &c
+		case isSVG(el.Name, "path"):
+			err = ps.path(&c.Path, el, raw)
+			c.Path.Parent = &c
+		case isSVG(el.Name, "text"):
+			err = ps.text(&c.Text, el, raw)
+			c.IsText = true
+			c.Text.Parent = &c
+		default:
+			c.Other.Raw, err = ps.other(el, raw)
+			c.IsOther = true
+		}
+		if err != nil {
+			return false, "", err
+		}
+		g.Children = append(g.Children, c)
+		return true, "", nil
+	}
+	other := func(tok xml.Token, raw string) bool {
+		if _, ok := tok.(xml.CharData); ok {
+			// Whitespace and other text goes into the space.
+			return false
+		}
+		// Comments and so on become children, so that they stay in
+		// the same place if the group is altered.
+		if len(raw) == 0 {
+			raw = tokenText(tok)
+		}
+		var c Child
+		c.Parent = g
+		c.IsOther = true
+		c.Other.Raw = raw
+		g.Children = append(g.Children, c)
+		return true
+	}
+	return ps.contents(start, tag, &g.Format, child, other)
+}
+
+// Read an svg element. Anything in it other than groups is kept in
+// the Format.
+func (ps *parser) svg(svg *SVG, start xml.StartElement, tag string) (err error) {
+	svg.XMLName = start.Name
+	start.Attr = kvgAttrs(start.Attr)
+	for _, attr := range start.Attr {
+		switch attr.Name {
+		case xml.Name{Local: "xmlns"}:
+			svg.XMLNS = attr.Value
+		case xml.Name{Local: "width"}:
+			svg.Width = attr.Value
+		case xml.Name{Local: "height"}:
+			svg.Height = attr.Value
+		case xml.Name{Local: "viewBox"}:
+			svg.ViewBox = attr.Value
+		default:
+			svg.Extra = append(svg.Extra, attr)
+		}
+	}
+	return ps.contents(start, tag, &svg.Format, func(el xml.StartElement, raw string) (bool, string, error) {
+		if !isSVG(el.Name, "g") {
+			return ps.spaceElement(el, raw)
+		}
+		var g Group
+		err := ps.group(&g, el, raw)
+		if err != nil {
+			return false, "", err
+		}
+		svg.Groups = append(svg.Groups, g)
+		return true, "", nil
+	}, nil)
+}
+
+// Read a whole file from the start, keeping everything before and
+// after the svg element in the prolog and epilog.
+func (ps *parser) file(svg *SVG) (err error) {
+	for {
+		begin := ps.d.InputOffset()
+		tok, raw, err := ps.token()
+		if err == io.EOF {
+			return fmt.Errorf("no svg element: %w", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if ps.src != nil {
+			svg.Prolog = ps.src.text(0, begin)
+		}
+		err = ps.svg(svg, start, raw)
+		if err != nil {
+			return err
+		}
+		if ps.src != nil {
+			svg.Epilog = ps.src.rest(ps.d.InputOffset())
+		}
+		for i := range svg.Groups {
+			svg.Groups[i].linkParents()
+		}
+		return nil
+	}
+}
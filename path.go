@@ -1,12 +1,13 @@
 package kvg
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 )
 
 // structure containing information about path commands as by specification
-var allCommands commands
+var allCommands = getCommands()
 
 type commands struct {
 	all        []string
@@ -40,16 +41,14 @@ func (c commands) isCommand(token string) bool {
 // attribute of a path element.
 type PathParserError struct {
 	msg string
+	// Offset is the position of the problem in the 'd' attribute,
+	// counted in bytes from zero. Since path data is ASCII, this is
+	// also the position in characters.
+	Offset int
 }
 
 func (err PathParserError) Error() string {
-	return err.msg
-}
-
-// token can contain an operator or an operand as string.
-type token struct {
-	value    string
-	operator bool
+	return fmt.Sprintf("%s at offset %d", err.msg, err.Offset)
 }
 
 // Command is a representation of an SVG path command and its parameters.
@@ -98,6 +97,32 @@ func (s Subpath) Compare(o Subpath) bool {
 // SVGPath is a collection of all the subpaths in 'd' attribute.
 type SVGPath struct {
 	Subpaths []Subpath
+	// How each command was written, in order, if the path was read by
+	// PathParser.
+	layout []commandLayout
+}
+
+// commandLayout is how a command was written in the 'd' attribute it
+// was read from, so that an unchanged command can be written the same
+// way.
+type commandLayout struct {
+	// The text of the command, from the end of the command before it,
+	// so that the texts of all the commands make up the whole 'd'
+	// attribute.
+	raw string
+	// The command as it was read.
+	command Command
+	// The letter of the command was left out, so it repeats the
+	// command before it.
+	implicit bool
+}
+
+// unchanged returns true if c is the command which was read.
+func (l *commandLayout) unchanged(c Command) bool {
+	if c.Symbol != l.command.Symbol || len(c.Params) != len(l.command.Params) {
+		return false
+	}
+	return c.Compare(l.command)
 }
 
 // Compare compares two paths.
@@ -113,106 +138,214 @@ func (p SVGPath) Compare(o SVGPath) bool {
 	return true
 }
 
-func reverse(ops []float64) []float64 {
-	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
-		ops[i], ops[j] = ops[j], ops[i]
+// pathScanner reads a 'd' attribute according to the grammar for path
+// data in the SVG 1.1 specification. pos is the offset in bytes of the
+// next character to read.
+type pathScanner struct {
+	raw string
+	pos int
+}
+
+// fail makes a PathParserError for the current position.
+func (s *pathScanner) fail(format string, a ...interface{}) error {
+	return PathParserError{msg: fmt.Sprintf(format, a...), Offset: s.pos}
+}
+
+// atEnd returns true if there is nothing left to read.
+func (s *pathScanner) atEnd() bool {
+	return s.pos >= len(s.raw)
+}
+
+// peek returns the next character, or zero at the end.
+func (s *pathScanner) peek() byte {
+	if s.atEnd() {
+		return 0
+	}
+	return s.raw[s.pos]
+}
+
+func isWsp(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// skipWsp skips any whitespace.
+func (s *pathScanner) skipWsp() {
+	for isWsp(s.peek()) {
+		s.pos++
+	}
+}
+
+// commaWsp skips a separator between numbers, which is whitespace with
+// at most one comma. It returns true if there was a comma.
+func (s *pathScanner) commaWsp() (comma bool) {
+	s.skipWsp()
+	if s.peek() == ',' {
+		s.pos++
+		comma = true
+		s.skipWsp()
+	}
+	return comma
+}
+
+// atNumber returns true if a number starts at the current position.
+func (s *pathScanner) atNumber() bool {
+	c := s.peek()
+	return isDigit(c) || c == '.' || c == '+' || c == '-'
+}
+
+// digits skips a digit sequence and returns its length.
+func (s *pathScanner) digits() int {
+	start := s.pos
+	for isDigit(s.peek()) {
+		s.pos++
 	}
-	return ops
+	return s.pos - start
 }
 
-func addOperand(tokens []token, operand string) ([]token, string) {
-	if operand != "" {
-		tokens = append(tokens, token{operand, false})
-		operand = ""
+// number reads a number, which may only have a sign if signed is true.
+// A number ends as soon as the next character cannot be part of it, so
+// "1.5.5" is two numbers and "1-2" is two numbers.
+func (s *pathScanner) number(signed bool) (float64, error) {
+	start := s.pos
+	if c := s.peek(); c == '+' || c == '-' {
+		if !signed {
+			return 0, s.fail("expected a non-negative number")
+		}
+		s.pos++
+	}
+	n := s.digits()
+	if s.peek() == '.' {
+		s.pos++
+		n += s.digits()
+	}
+	if n == 0 {
+		s.pos = start
+		return 0, s.fail("expected a number")
 	}
-	return tokens, operand
+	if c := s.peek(); c == 'e' || c == 'E' {
+		mantissa := s.pos
+		s.pos++
+		if c := s.peek(); c == '+' || c == '-' {
+			s.pos++
+		}
+		if s.digits() == 0 {
+			// The "e" is not part of the number.
+			s.pos = mantissa
+		}
+	}
+	value, err := strconv.ParseFloat(s.raw[start:s.pos], 64)
+	if err != nil {
+		s.pos = start
+		return 0, s.fail("bad number: %s", err)
+	}
+	return value, nil
 }
 
-// tokenize takes value of 'd' attribute and transforms it to series of
-// operators and operands - step 1.
-func tokenize(raw string) []token {
-	var (
-		tokens  []token
-		operand string
-	)
-	for _, r := range raw {
-		char := string(r)
+// flag reads an arc flag, which is a single "0" or "1" and need not be
+// followed by a separator.
+func (s *pathScanner) flag() (float64, error) {
+	switch s.peek() {
+	case '0':
+		s.pos++
+		return 0, nil
+	case '1':
+		s.pos++
+		return 1, nil
+	}
+	return 0, s.fail("expected a flag, 0 or 1")
+}
+
+// arguments reads one set of the parameters of the command symbol.
+func (s *pathScanner) arguments(symbol byte) (params []float64, err error) {
+	lower := strings.ToLower(string(symbol))
+	n := allCommands.parameters[lower]
+	params = make([]float64, n)
+	for i := range params {
+		if i > 0 {
+			s.commaWsp()
+		}
 		switch {
-		case allCommands.isCommand(char):
-			tokens, operand = addOperand(tokens, operand)
-			tokens = append(tokens, token{char, true})
-		case char == ".":
-			if operand == "" {
-				operand = "0"
-			}
-			if strings.Contains(operand, char) {
-				tokens = append(tokens, token{operand, false})
-				operand = "0"
+		case lower == "a" && (i == 0 || i == 1):
+			params[i], err = s.number(false)
+		case lower == "a" && (i == 3 || i == 4):
+			params[i], err = s.flag()
+		default:
+			params[i], err = s.number(true)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// toCommands reads the whole of a 'd' attribute into Command objects,
+// with how each was written. The parameters of each command may be
+// repeated without repeating the command, in which case each set of
+// parameters becomes a separate Command, except that the parameters
+// after the first pair of a moveto are linetos.
+func (s *pathScanner) toCommands() (commands []Command, layout []commandLayout, err error) {
+	// The end of the text of the last command.
+	end := 0
+	add := func(c Command, implicit bool) {
+		commands = append(commands, c)
+		read := Command{c.Symbol, append([]float64(nil), c.Params...)}
+		layout = append(layout, commandLayout{s.raw[end:s.pos], read, implicit})
+		end = s.pos
+	}
+	s.skipWsp()
+	for !s.atEnd() {
+		symbol := s.peek()
+		if !allCommands.isCommand(string(symbol)) {
+			return commands, layout, s.fail("expected a command")
+		}
+		if len(commands) == 0 && symbol != 'M' && symbol != 'm' {
+			return commands, layout, s.fail("path does not start with a moveto")
+		}
+		s.pos++
+		s.skipWsp()
+		if allCommands.parameters[strings.ToLower(string(symbol))] == 0 {
+			add(Command{Symbol: string(symbol)}, false)
+			continue
+		}
+		for implicit := false; ; implicit = true {
+			params, err := s.arguments(symbol)
+			if err != nil {
+				return commands, layout, err
 			}
-			fallthrough
-		case char >= "0" && char <= "9" || char == "e":
-			operand += char
-		case char == "-":
-			if strings.HasSuffix(operand, "e") {
-				operand += char
-			} else {
-				tokens, operand = addOperand(tokens, operand)
-				operand = char
+			add(Command{string(symbol), params}, implicit)
+			switch symbol {
+			case 'M':
+				symbol = 'L'
+			case 'm':
+				symbol = 'l'
 			}
-		default:
-			tokens, operand = addOperand(tokens, operand)
-		}
-	}
-	tokens, operand = addOperand(tokens, operand)
-	return tokens
-}
-
-// toCommands takes a collection of operators and operands and produces
-// Command objects - step 2.
-func toCommands(tokens []token) (commands []Command, err error) {
-	var operands []float64
-	for i := len(tokens) - 1; i >= 0; i-- {
-		t := tokens[i]
-		if t.operator {
-			nParam := allCommands.parameters[strings.ToLower(t.value)]
-			nOperand := len(operands)
-			if nParam == 0 && nOperand == 0 {
-				command := Command{Symbol: t.value}
-				commands = append([]Command{command}, commands...)
-			} else if nParam != 0 && nOperand%nParam == 0 {
-				loopCount := nOperand / nParam
-				for i := 0; i < loopCount; i++ {
-					operator := t.value
-					if operator == "m" && i < loopCount-1 {
-						operator = "l"
-					}
-					if operator == "M" && i < loopCount-1 {
-						operator = "L"
-					}
-					command := Command{operator, reverse(operands[:nParam])}
-					commands = append([]Command{command}, commands...)
-					operands = operands[nParam:]
-				}
-			} else {
-				err := PathParserError{"Incorrect number of parameters for " + t.value}
-				return commands, err
+			comma := s.commaWsp()
+			if s.atNumber() {
+				continue
 			}
-		} else {
-			number, err := strconv.ParseFloat(t.value, 64)
-			if err != nil {
-				return commands, err
+			if comma {
+				return commands, layout, s.fail("expected a number after comma")
 			}
-			operands = append(operands, number)
+			break
 		}
 	}
-	return commands, nil
+	if len(layout) > 0 {
+		// The whitespace at the end.
+		layout[len(layout)-1].raw += s.raw[end:]
+	}
+	return commands, layout, nil
 }
 
 // createSubpaths takes a collection of Command objects and determines
 // all subpaths within the collection - step 3.
 func createSubpaths(commands []Command) (path SVGPath) {
 	var subpath []Command
-	for i, command := range commands {
+	for _, command := range commands {
 		switch strings.ToLower(command.Symbol) {
 		case allCommands.start:
 			if len(subpath) > 0 {
@@ -225,21 +358,200 @@ func createSubpaths(commands []Command) (path SVGPath) {
 			subpath = []Command{}
 		default:
 			subpath = append(subpath, command)
-			if len(commands) == i+1 {
-				path.Subpaths = append(path.Subpaths, Subpath{subpath})
-			}
 		}
 	}
+	if len(subpath) > 0 {
+		path.Subpaths = append(path.Subpaths, Subpath{subpath})
+	}
 	return path
 }
 
 // PathParser takes value of a 'd' attribute and transforms it to collection of
-// subpaths and commands.
+// subpaths and commands, following the path data grammar of SVG 1.1.
+// Errors are of type PathParserError.
 func PathParser(raw string) (path SVGPath, err error) {
-	allCommands = getCommands()
-	commands, err := toCommands(tokenize(raw))
+	s := pathScanner{raw: raw}
+	commands, layout, err := s.toCommands()
 	if err != nil {
 		return path, err
 	}
-	return createSubpaths(commands), nil
+	path = createSubpaths(commands)
+	path.layout = layout
+	return path, nil
+}
+
+// PathCoordinates says whether SVGPath.Text writes absolute or relative
+// commands.
+type PathCoordinates int
+
+const (
+	// PathAsIs keeps each command as it is.
+	PathAsIs PathCoordinates = iota
+	// PathAbsolute makes every command absolute, like "C".
+	PathAbsolute
+	// PathRelative makes every command relative, like "c".
+	PathRelative
+)
+
+// PathOptions holds the options for SVGPath.Text. The zero value
+// writes the commands as they are, in the compact KanjiVG style, except
+// that the commands read by PathParser which have not been changed are
+// written as they were read.
+type PathOptions struct {
+	// Precision is the largest number of digits after the decimal
+	// point. If it is zero, each number is written with as many digits
+	// as it needs. Converting between absolute and relative commands
+	// can give numbers like 23.419999999999998 unless this is set.
+	Precision int
+	// Coordinates chooses absolute or relative commands.
+	Coordinates PathCoordinates
+	// Spaced separates the numbers and the commands with spaces,
+	// instead of the KanjiVG style, which separates numbers with a
+	// comma, except before a minus sign, and does not separate the
+	// commands.
+	Spaced bool
+}
+
+// String returns the path as a 'd' attribute in the KanjiVG style. A
+// path read by PathParser gives back the same string, with the same
+// separators, numbers and repeated commands, if it has not been
+// changed.
+func (p SVGPath) String() string {
+	return p.Text(nil)
+}
+
+// Text returns the path as a 'd' attribute, written according to opts,
+// which may be nil. Every command which is not written as it was read
+// is written with its letter, even if it is the same as the previous
+// one.
+func (p SVGPath) Text(opts *PathOptions) string {
+	if opts == nil {
+		opts = &PathOptions{}
+	}
+	switch opts.Coordinates {
+	case PathAbsolute:
+		p = p.convert(true)
+	case PathRelative:
+		p = p.convert(false)
+	}
+	asRead := *opts == PathOptions{}
+	var b strings.Builder
+	// The number of commands so far, and whether the last one was
+	// written as it was read. A command which was read with its letter
+	// left out can only be written that way after the command it
+	// repeats.
+	n, lastRead := 0, false
+	for _, subpath := range p.Subpaths {
+		for _, command := range subpath.Commands {
+			n++
+			if asRead && n <= len(p.layout) {
+				l := &p.layout[n-1]
+				if l.unchanged(command) && (lastRead || !l.implicit) {
+					b.WriteString(l.raw)
+					lastRead = true
+					continue
+				}
+			}
+			lastRead = false
+			if opts.Spaced && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(command.Symbol)
+			for i, param := range command.Params {
+				number := formatNumber(param, opts.Precision)
+				if i > 0 {
+					if opts.Spaced {
+						b.WriteByte(' ')
+					} else if number[0] != '-' {
+						b.WriteByte(',')
+					}
+				}
+				b.WriteString(number)
+			}
+		}
+	}
+	return b.String()
+}
+
+// formatNumber writes v with at most precision digits after the decimal
+// point, or as many as it needs if precision is zero.
+func formatNumber(v float64, precision int) string {
+	if precision <= 0 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return s
+}
+
+// convert returns a copy of the path with all the commands made
+// absolute if absolute is true, or relative otherwise.
+func (p SVGPath) convert(absolute bool) (c SVGPath) {
+	var cx, cy, sx, sy float64
+	for _, subpath := range p.Subpaths {
+		var commands []Command
+		for _, command := range subpath.Commands {
+			lower := strings.ToLower(command.Symbol)
+			abs := append([]float64(nil), command.Params...)
+			// The positions of the x coordinates in the parameters,
+			// each followed by its y coordinate.
+			var xs []int
+			switch lower {
+			case "m", "l", "t", "c", "s", "q":
+				for i := 0; i < len(abs); i += 2 {
+					xs = append(xs, i)
+				}
+			case "a":
+				xs = []int{5}
+			}
+			if !command.IsAbsolute() {
+				for _, i := range xs {
+					abs[i] += cx
+					abs[i+1] += cy
+				}
+				switch lower {
+				case "h":
+					abs[0] += cx
+				case "v":
+					abs[0] += cy
+				}
+			}
+			params := abs
+			symbol := strings.ToUpper(lower)
+			if !absolute {
+				params = append([]float64(nil), abs...)
+				for _, i := range xs {
+					params[i] -= cx
+					params[i+1] -= cy
+				}
+				switch lower {
+				case "h":
+					params[0] -= cx
+				case "v":
+					params[0] -= cy
+				}
+				symbol = lower
+			}
+			commands = append(commands, Command{symbol, params})
+			switch lower {
+			case "z":
+				cx, cy = sx, sy
+			case "h":
+				cx = abs[0]
+			case "v":
+				cy = abs[0]
+			default:
+				cx, cy = abs[len(abs)-2], abs[len(abs)-1]
+				if lower == "m" {
+					sx, sy = cx, cy
+				}
+			}
+		}
+		c.Subpaths = append(c.Subpaths, Subpath{commands})
+	}
+	return c
 }
@@ -0,0 +1,221 @@
+package kvg
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPathParser(t *testing.T) {
+	tests := []struct {
+		d      string
+		expect []Command
+	}{
+		{"M1e2,3E-1", []Command{{"M", []float64{100, 0.3}}}},
+		{"M1.5.5-2e", nil},
+		{"M0,0a1,1 0 011,1", []Command{
+			{"M", []float64{0, 0}},
+			{"a", []float64{1, 1, 0, 0, 1, 1, 1}},
+		}},
+		{"M1 2 3 4", []Command{{"M", []float64{1, 2}}, {"L", []float64{3, 4}}}},
+		{"m1-2-3-4", []Command{{"m", []float64{1, -2}}, {"l", []float64{-3, -4}}}},
+		{"M0,0c1,2,3,4,5,6 7,8,9,10,11,12", []Command{
+			{"M", []float64{0, 0}},
+			{"c", []float64{1, 2, 3, 4, 5, 6}},
+			{"c", []float64{7, 8, 9, 10, 11, 12}},
+		}},
+		{" M.5,+.5 h1 2 v3z L1,1 ", []Command{
+			{"M", []float64{0.5, 0.5}},
+			{"h", []float64{1}},
+			{"h", []float64{2}},
+			{"v", []float64{3}},
+			{"z", nil},
+			{"L", []float64{1, 1}},
+		}},
+	}
+	for _, test := range tests {
+		path, err := PathParser(test.d)
+		if test.expect == nil {
+			if err == nil {
+				t.Errorf("%s: no error", test.d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.d, err)
+			continue
+		}
+		var got []Command
+		for _, s := range path.Subpaths {
+			got = append(got, s.Commands...)
+		}
+		if len(got) != len(test.expect) {
+			t.Errorf("%s: expected %v, got %v", test.d, test.expect, got)
+			continue
+		}
+		for i := range got {
+			if len(got[i].Params) != len(test.expect[i].Params) || !got[i].Compare(test.expect[i]) {
+				t.Errorf("%s: expected %v, got %v", test.d, test.expect[i], got[i])
+			}
+		}
+	}
+	errs := []struct {
+		d      string
+		offset int
+	}{
+		{"L1 2", 0},
+		{"M1 2 3", 6},
+		{"M1,,2", 3},
+		{"M1 2,", 5},
+		{"M1 2,L3 4", 5},
+		{"M0 0a-1 1 0 0 1 2 2", 5},
+		{"M0 0a1 1 0 2 1 2 2", 11},
+		{"M1e", 2},
+		{"M1 2 z 3", 7},
+	}
+	for _, e := range errs {
+		_, err := PathParser(e.d)
+		var perr PathParserError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: expected PathParserError, got %v", e.d, err)
+			continue
+		}
+		if perr.Offset != e.offset {
+			t.Errorf("%s: expected offset %d, got %d", e.d, e.offset, perr.Offset)
+		}
+	}
+}
+
+func TestPathParserKanji(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range svg.GetPaths() {
+		path, err := PathParser(p.D)
+		if err != nil || len(path.Subpaths) != 1 {
+			t.Errorf("%s: %v", p.ID, err)
+		}
+	}
+}
+
+func TestPathText(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range svg.GetPaths() {
+		path, err := PathParser(p.D)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path.String() != p.D {
+			t.Errorf("%s: %s became %s", p.ID, p.D, path)
+		}
+		rel := path.Text(&PathOptions{Coordinates: PathRelative, Precision: 2})
+		back, err := PathParser(rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back.Text(&PathOptions{Coordinates: PathAsIs, Precision: 2}) != rel {
+			t.Errorf("%s: relative path %s changed", p.ID, rel)
+		}
+		abs := back.Text(&PathOptions{Coordinates: PathAbsolute, Precision: 2})
+		if abs != path.Text(&PathOptions{Coordinates: PathAbsolute, Precision: 2}) {
+			t.Errorf("%s: absolute path %s differs", p.ID, abs)
+		}
+	}
+	// Paths which are not in the KanjiVG style are written as they were
+	// read, as long as they are not changed.
+	for _, d := range []string{
+		"M10,20c1,2,3,4,5,6,7,8,9,10,11,12",
+		"M10,20l1,2,3,4",
+		"M10 20 30 40",
+		"M1.5.5",
+		" M.5,+.5 h1 2 v3z L1e1,1.50 ",
+		"m0,0a1,1 0 011,1",
+	} {
+		path, err := PathParser(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path.String() != d {
+			t.Errorf("%q became %q", d, path)
+		}
+	}
+	// A changed command is written in the KanjiVG style, and so is a
+	// command after it which had its letter left out.
+	path, err := PathParser("M10 20 30 40 50 60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path.Subpaths[0].Commands[1].Params[0] = 35
+	if got := path.String(); got != "M10 20L35,40L50,60" {
+		t.Errorf("Changed path became %s", got)
+	}
+	path, err = PathParser("M1,2c0.5-1.75,3.333,4,5,6h-7z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		opts   PathOptions
+		expect string
+	}{
+		{PathOptions{Precision: 1}, "M1,2c0.5-1.8,3.3,4,5,6h-7z"},
+		{PathOptions{Spaced: true}, "M1 2 c0.5 -1.75 3.333 4 5 6 h-7 z"},
+		{PathOptions{Coordinates: PathAbsolute}, "M1,2C1.5,0.25,4.333,6,6,8H-1Z"},
+		{PathOptions{Coordinates: PathRelative}, "m1,2c0.5-1.75,3.333,4,5,6h-7z"},
+	}
+	for _, test := range tests {
+		got := path.Text(&test.opts)
+		if got != test.expect {
+			t.Errorf("%+v: expected %s, got %s", test.opts, test.expect, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		d, expect string
+	}{
+		{"m1,1h10v10H1z", "M1,1L11,1L11,11L1,11Z"},
+		{"M0,0C1,2,3,4,5,5s5,5,10,0", "M0,0C1,2,3,4,5,5C7,6,10,10,15,5"},
+		{"M0,0c1,2,3,4,5,5S10,10,15,5", "M0,0C1,2,3,4,5,5C7,6,10,10,15,5"},
+		{"M0,0L5,5S10,10,15,5", "M0,0L5,5C5,5,10,10,15,5"},
+		{"M0,0Q5,5,10,0t10,0", "M0,0Q5,5,10,0Q15-5,20,0"},
+		{"M0,0T10,0", "M0,0Q0,0,10,0"},
+		{"M0,0A0,5,0,0,1,10,0", "M0,0L10,0"},
+		{"M0,0A5,5,0,0,1,0,0", "M0,0"},
+	}
+	for _, test := range tests {
+		path, err := PathParser(test.d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := path.Normalize()
+		if got.String() != test.expect || !got.IsNormal() {
+			t.Errorf("%s: expected %s, got %s", test.d, test.expect, got)
+		}
+	}
+	// A half circle of radius 10 from (0,0) to (20,0), first going up
+	// the page, then going down.
+	for _, sweep := range []string{"0", "1"} {
+		path, err := PathParser("M0,0a10,10,0,0," + sweep + ",20,0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmds := path.Normalize().Subpaths[0].Commands
+		if len(cmds) != 3 {
+			t.Fatalf("Expected two cubics, got %v", cmds)
+		}
+		mid := cmds[1].Params[4:]
+		y := 10.0
+		if sweep == "1" {
+			y = -10
+		}
+		end := cmds[2].Params[4:]
+		if math.Abs(mid[0]-10) > 1e-9 || math.Abs(mid[1]-y) > 1e-9 || end[0] != 20 || end[1] != 0 {
+			t.Errorf("Sweep %s: bad arc %v", sweep, cmds)
+		}
+	}
+}
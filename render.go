@@ -0,0 +1,371 @@
+package kvg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Options for Render. The zero value draws the kanji at the size in
+// the file, in the colours of the file, on a transparent background.
+type RenderOptions struct {
+	// The size of the image in pixels. If either is zero, it is the
+	// width or height of the file, in proportion to the other if that
+	// is given.
+	Width, Height int
+	// The background colour, or nil for transparent.
+	Background color.Color
+	// The colours of the strokes, used in turn. If this is empty, the
+	// stroke colour in the style of the StrokePaths group is used.
+	Colors []color.Color
+	// The width of the strokes, in units of the viewBox. If this is
+	// zero, the stroke-width in the style of the StrokePaths group is
+	// used.
+	StrokeWidth float64
+	// Draw the stroke numbers.
+	Numbers bool
+	// The colour of the stroke numbers. If this is nil, the fill
+	// colour in the style of the StrokeNumbers group is used.
+	NumberColor color.Color
+}
+
+// Parse a colour of the form "#rrggbb" or "#rgb", or one of a few
+// names, as used in the styles of the KanjiVG files.
+func ParseColor(s string) (c color.RGBA, err error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	switch s {
+	case "black":
+		return color.RGBA{A: 255}, nil
+	case "white":
+		return color.RGBA{255, 255, 255, 255}, nil
+	case "none", "transparent":
+		return color.RGBA{}, nil
+	}
+	if !strings.HasPrefix(s, "#") || (len(s) != 4 && len(s) != 7) {
+		return c, fmt.Errorf("cannot parse colour '%s'", s)
+	}
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return c, fmt.Errorf("cannot parse colour '%s'", s)
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, nil
+}
+
+// The colour of property name in style, or def if there is none.
+func styleColor(style, name string, def color.Color) color.Color {
+	c, err := ParseColor(styleProperty(style, name))
+	if err != nil {
+		return def
+	}
+	return c
+}
+
+// The area of the SVG which is drawn, from its viewBox, or its width
+// and height, or the usual size of the KanjiVG files.
+func (kanjivg *SVG) viewBox() Rect {
+	f := strings.FieldsFunc(kanjivg.ViewBox, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	if len(f) == 4 {
+		var v [4]float64
+		ok := true
+		for i := range f {
+			var err error
+			v[i], err = strconv.ParseFloat(f[i], 64)
+			ok = ok && err == nil
+		}
+		if ok && v[2] > 0 && v[3] > 0 {
+			return Rect{Point{v[0], v[1]}, Point{v[0] + v[2], v[1] + v[3]}}
+		}
+	}
+	w, errw := strconv.ParseFloat(strings.TrimSuffix(kanjivg.Width, "px"), 64)
+	h, errh := strconv.ParseFloat(strings.TrimSuffix(kanjivg.Height, "px"), 64)
+	if errw != nil || errh != nil || w <= 0 || h <= 0 {
+		w, h = 109, 109
+	}
+	return Rect{Point{}, Point{w, h}}
+}
+
+// Draws antialiased lines with round ends and joins onto an image, by
+// working out the coverage of each pixel from its distance to the
+// nearest line.
+type rasterizer struct {
+	img *image.RGBA
+	// The coverage of each pixel by the stroke being drawn.
+	mask []float32
+	// The area of the mask which has been used.
+	used image.Rectangle
+	// The change from SVG to pixel coordinates.
+	m Matrix
+}
+
+// Make a rasterizer drawing the area box of the SVG onto img.
+func newRasterizer(img *image.RGBA, box Rect, dst image.Rectangle) *rasterizer {
+	from := box
+	to := Rect{Point{float64(dst.Min.X), float64(dst.Min.Y)}, Point{float64(dst.Max.X), float64(dst.Max.Y)}}
+	m := Translate(-from.Min.X, -from.Min.Y).
+		Then(Scale(to.Width()/from.Width(), to.Height()/from.Height(), Point{})).
+		Then(Translate(to.Min.X, to.Min.Y))
+	return &rasterizer{
+		img:  img,
+		mask: make([]float32, img.Rect.Dx()*img.Rect.Dy()),
+		m:    m,
+	}
+}
+
+// The scale from SVG to pixels, for widths.
+func (r *rasterizer) scale() float64 {
+	return math.Sqrt(math.Abs(r.m.A*r.m.D - r.m.B*r.m.C))
+}
+
+// Add the coverage of the line from a to b, in pixels, with half width
+// hw, to the mask.
+func (r *rasterizer) line(a, b Point, hw float64) {
+	bounds := r.img.Rect
+	box := image.Rect(
+		int(math.Floor(math.Min(a.X, b.X)-hw-1)), int(math.Floor(math.Min(a.Y, b.Y)-hw-1)),
+		int(math.Ceil(math.Max(a.X, b.X)+hw+1)), int(math.Ceil(math.Max(a.Y, b.Y)+hw+1)),
+	).Intersect(bounds)
+	if box.Empty() {
+		return
+	}
+	r.used = r.used.Union(box)
+	ab := b.sub(a)
+	l2 := ab.X*ab.X + ab.Y*ab.Y
+	for y := box.Min.Y; y < box.Max.Y; y++ {
+		for x := box.Min.X; x < box.Max.X; x++ {
+			p := Point{float64(x) + 0.5, float64(y) + 0.5}
+			ap := p.sub(a)
+			t := 0.0
+			if l2 > 0 {
+				t = math.Max(0, math.Min(1, (ap.X*ab.X+ap.Y*ab.Y)/l2))
+			}
+			d := ap.sub(ab.scale(t)).Len()
+			cov := float32(math.Max(0, math.Min(1, hw+0.5-d)))
+			i := (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+			if cov > r.mask[i] {
+				r.mask[i] = cov
+			}
+		}
+	}
+}
+
+// Paint the mask onto the image in the colour c, and clear it.
+func (r *rasterizer) paint(c color.Color) {
+	cr, cg, cb, ca := c.RGBA()
+	bounds := r.img.Rect
+	for y := r.used.Min.Y; y < r.used.Max.Y; y++ {
+		for x := r.used.Min.X; x < r.used.Max.X; x++ {
+			i := (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+			cov := float64(r.mask[i])
+			if cov == 0 {
+				continue
+			}
+			r.mask[i] = 0
+			o := r.img.PixOffset(x, y)
+			pix := r.img.Pix[o : o+4 : o+4]
+			keep := 1 - cov*float64(ca)/0xffff
+			for j, v := range []uint32{cr, cg, cb, ca} {
+				pix[j] = uint8(math.Round(float64(v>>8)*cov + float64(pix[j])*keep))
+			}
+		}
+	}
+	r.used = image.Rectangle{}
+}
+
+// Draw the stroke path with width w in the colour c.
+func (r *rasterizer) stroke(path SVGPath, w float64, c color.Color) {
+	scale := r.scale()
+	hw := w * scale / 2
+	path = path.Transform(r.m)
+	segs := path.segments()
+	if len(segs) == 0 && len(path.Subpaths) > 0 {
+		// A dot, drawn as a round cap.
+		start := path.Start()
+		r.line(start, start, hw)
+	}
+	for _, s := range segs {
+		points := s.flatten(0.1, []Point{s[0]}, 0)
+		for i := 1; i < len(points); i++ {
+			r.line(points[i-1], points[i], hw)
+		}
+	}
+	r.paint(c)
+}
+
+// Bitmaps of the digits, five pixels wide and seven high, with the
+// top row first and the most significant bit on the left.
+var digitGlyphs = [10][7]uint8{
+	{0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	{0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	{0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	{0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	{0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	{0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	{0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	{0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	{0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	{0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+}
+
+// Draw the digits of text with the left end of the baseline at pos,
+// in SVG coordinates, at the font size size, in the colour c. Other
+// characters are left as spaces.
+func (r *rasterizer) text(text string, pos Point, size float64, c color.Color) {
+	// The digits are about as high as capital letters, and there is
+	// one pixel of the glyph between them.
+	cell := size * 0.7 / 7 * r.scale()
+	origin := r.m.Apply(pos)
+	// Sample each pixel at four by four points for smooth edges.
+	const n = 4
+	for k, ch := range text {
+		x0 := origin.X + float64(k)*6*cell
+		y0 := origin.Y - 7*cell
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		g := &digitGlyphs[ch-'0']
+		box := image.Rect(int(math.Floor(x0)), int(math.Floor(y0)),
+			int(math.Ceil(x0+5*cell)), int(math.Ceil(origin.Y)))
+		bounds := r.img.Rect
+		box = box.Intersect(bounds)
+		r.used = r.used.Union(box)
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				hits := 0
+				for sy := 0; sy < n; sy++ {
+					for sx := 0; sx < n; sx++ {
+						col := int((float64(x) + (float64(sx)+0.5)/n - x0) / cell)
+						row := int((float64(y) + (float64(sy)+0.5)/n - y0) / cell)
+						if col >= 0 && col < 5 && row >= 0 && row < 7 && g[row]&(0x10>>col) != 0 {
+							hits++
+						}
+					}
+				}
+				i := (y-bounds.Min.Y)*bounds.Dx() + x - bounds.Min.X
+				if cov := float32(hits) / (n * n); cov > r.mask[i] {
+					r.mask[i] = cov
+				}
+			}
+		}
+	}
+	r.paint(c)
+}
+
+// The size of the image for opts and the area box of the SVG.
+func imageSize(box Rect, opts *RenderOptions) (w, h int) {
+	w, h = opts.Width, opts.Height
+	switch {
+	case w <= 0 && h <= 0:
+		w, h = int(math.Round(box.Width())), int(math.Round(box.Height()))
+	case w <= 0:
+		w = int(math.Round(float64(h) * box.Width() / box.Height()))
+	case h <= 0:
+		h = int(math.Round(float64(w) * box.Height() / box.Width()))
+	}
+	return w, h
+}
+
+// How to draw each stroke, for drawKanji. If the colour of a stroke
+// is nil, it is not drawn.
+type strokeColors func(i int) color.Color
+
+// Draw the strokes of kanjivg, and their numbers if numbers is true,
+// in the area dst of img, with the colours given by colors.
+func drawKanji(img *image.RGBA, dst image.Rectangle, kanjivg *SVG, opts *RenderOptions,
+	colors strokeColors, numbers bool) (err error) {
+	if !kanjivg.HasBaseGroup() {
+		return ErrNoBaseGroup
+	}
+	r := newRasterizer(img, kanjivg.viewBox(), dst)
+	width := opts.StrokeWidth
+	if width <= 0 {
+		width = strokeWidth(kanjivg)
+	}
+	paths := kanjivg.GetPaths()
+	parsed := make([]SVGPath, len(paths))
+	for i, p := range paths {
+		parsed[i], err = p.SVGPath()
+		if err != nil {
+			return &PathError{ID: p.ID, Err: err}
+		}
+	}
+	for i := range paths {
+		if c := colors(i); c != nil {
+			r.stroke(parsed[i], width, c)
+		}
+	}
+	labels := kanjivg.labels()
+	if !numbers || labels == nil {
+		return nil
+	}
+	size, err := strconv.ParseFloat(styleProperty(labels.Style, "font-size"), 64)
+	if err != nil || size <= 0 {
+		size = 8
+	}
+	c := opts.NumberColor
+	if c == nil {
+		c = styleColor(labels.Style, "fill", color.RGBA{0x80, 0x80, 0x80, 0xff})
+	}
+	for _, p := range paths {
+		t := labels.Label(p)
+		if t == nil {
+			continue
+		}
+		pos, err := t.Position()
+		if err != nil {
+			continue
+		}
+		r.text(string(t.Content), pos, size, c)
+	}
+	return nil
+}
+
+// Draw kanjivg as an image, with its strokes of the width in the style
+// of the StrokePaths group, with round ends and joins. The error is a
+// PathError if a stroke cannot be parsed, or ErrNoBaseGroup.
+func Render(kanjivg *SVG, opts *RenderOptions) (img *image.RGBA, err error) {
+	if opts == nil {
+		opts = &RenderOptions{}
+	}
+	box := kanjivg.viewBox()
+	w, h := imageSize(box, opts)
+	img = image.NewRGBA(image.Rect(0, 0, w, h))
+	if opts.Background != nil {
+		draw.Draw(img, img.Rect, image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	var def color.Color = color.Black
+	if len(kanjivg.Groups) > 0 {
+		def = styleColor(kanjivg.Groups[0].Style, "stroke", def)
+	}
+	colors := func(i int) color.Color {
+		if len(opts.Colors) > 0 {
+			return opts.Colors[i%len(opts.Colors)]
+		}
+		return def
+	}
+	err = drawKanji(img, img.Rect, kanjivg, opts, colors, opts.Numbers)
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// Draw kanjivg as Render does, and write it to w as a PNG.
+func RenderPNG(w io.Writer, kanjivg *SVG, opts *RenderOptions) (err error) {
+	img, err := Render(kanjivg, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,77 @@
+package kvg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	green := color.RGBA{0, 0xff, 0, 0xff}
+	img, err := Render(&svg, &RenderOptions{
+		Width:      218,
+		Background: color.White,
+		Colors:     []color.Color{red, green},
+		Numbers:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Rect != image.Rect(0, 0, 218, 218) {
+		t.Fatalf("Wrong size %v", img.Rect)
+	}
+	if c := img.RGBAAt(0, 0); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("Background is %v", c)
+	}
+	paths := svg.GetPaths()
+	for i, want := range []color.RGBA{red, green} {
+		path, err := paths[i].SVGPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The middle of a stroke is fully covered.
+		p := path.PointAt(0.5).scale(2)
+		if c := img.RGBAAt(int(p.X), int(p.Y)); c != want {
+			t.Errorf("Stroke %d at %v is %v", i+1, p, c)
+		}
+	}
+	// The first label has some grey in it.
+	pos, err := svg.labels().Label(paths[0]).Position()
+	if err != nil {
+		t.Fatal(err)
+	}
+	grey := 0
+	for y := int(pos.Y*2) - 12; y < int(pos.Y*2); y++ {
+		for x := int(pos.X * 2); x < int(pos.X*2)+8; x++ {
+			if img.RGBAAt(x, y) == (color.RGBA{0x80, 0x80, 0x80, 0xff}) {
+				grey++
+			}
+		}
+	}
+	if grey == 0 {
+		t.Errorf("No stroke number at %v", pos)
+	}
+	var buf bytes.Buffer
+	err = RenderPNG(&buf, &svg, &RenderOptions{Height: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds() != image.Rect(0, 0, 50, 50) {
+		t.Errorf("Wrong size of PNG %v", decoded.Bounds())
+	}
+	// Without a background, the corner is transparent.
+	if _, _, _, a := decoded.At(0, 0).RGBA(); a != 0 {
+		t.Errorf("Corner of PNG is not transparent")
+	}
+}
@@ -0,0 +1,140 @@
+package kvg
+
+import "math"
+
+// flatten adds the points of s to points, except its start, so that
+// no point of s is further than tolerance from the lines between them.
+func (s segment) flatten(tolerance float64, points []Point, depth int) []Point {
+	end := s[len(s)-1]
+	if len(s) == 2 || depth >= 16 || s.flatness() <= tolerance {
+		return append(points, end)
+	}
+	a, b := s.split()
+	points = a.flatten(tolerance, points, depth+1)
+	return b.flatten(tolerance, points, depth+1)
+}
+
+// flatness returns the furthest distance of the control points of s
+// from the line between its ends, which is at least as far as any
+// point of the curve.
+func (s segment) flatness() (d float64) {
+	start, end := s[0], s[len(s)-1]
+	chord := end.sub(start)
+	l := chord.Len()
+	for _, p := range s[1 : len(s)-1] {
+		v := p.sub(start)
+		if l == 0 {
+			d = math.Max(d, v.Len())
+			continue
+		}
+		d = math.Max(d, math.Abs(chord.X*v.Y-chord.Y*v.X)/l)
+	}
+	return d
+}
+
+// split divides s in half using de Casteljau's algorithm.
+func (s segment) split() (a, b segment) {
+	points := append(segment(nil), s...)
+	a = segment{points[0]}
+	b = segment{points[len(points)-1]}
+	for len(points) > 1 {
+		next := make(segment, len(points)-1)
+		for i := range next {
+			next[i] = points[i].add(points[i+1]).scale(0.5)
+		}
+		a = append(a, next[0])
+		b = append(segment{next[len(next)-1]}, b...)
+		points = next
+	}
+	return a, b
+}
+
+// Flatten returns the path as a polyline, with no point of the curves
+// further than tolerance from the lines between the points. The first
+// point is the start of the path. A path with more than one subpath
+// gives one polyline with all of them in it, joined by lines which are
+// not drawn.
+func (p SVGPath) Flatten(tolerance float64) (points []Point) {
+	points, _ = p.polyline(tolerance)
+	return points
+}
+
+// polyline returns the points of Flatten, and whether each point is
+// the start of a new subpath, so the line to it is not drawn.
+func (p SVGPath) polyline(tolerance float64) (points []Point, moves []bool) {
+	segs := p.segments()
+	if len(segs) == 0 {
+		if len(p.Subpaths) == 0 {
+			return nil, nil
+		}
+		return []Point{p.Start()}, []bool{false}
+	}
+	points = []Point{segs[0][0]}
+	for _, s := range segs {
+		if s[0] != points[len(points)-1] {
+			// A new subpath.
+			points = append(points, s[0])
+			moves = append(moves, true)
+		}
+		points = s.flatten(tolerance, points, 0)
+		for len(moves) < len(points) {
+			moves = append(moves, false)
+		}
+	}
+	return points, moves
+}
+
+// The tolerance of the polyline used by Resample.
+const resampleTolerance = 0.001
+
+// Resample returns n points along the path, equally spaced by their
+// distance along it, starting at the start of the path and finishing
+// at its end. As for Length, the distance does not include the gaps
+// between subpaths, so no point is in a gap.
+func (p SVGPath) Resample(n int) (points []Point) {
+	if n <= 0 {
+		return nil
+	}
+	line, moves := p.polyline(resampleTolerance)
+	if len(line) == 0 {
+		return nil
+	}
+	// The distance along the line of each point of it.
+	dist := make([]float64, len(line))
+	for i := 1; i < len(line); i++ {
+		dist[i] = dist[i-1]
+		if !moves[i] {
+			dist[i] += line[i].sub(line[i-1]).Len()
+		}
+	}
+	total := dist[len(dist)-1]
+	points = make([]Point, n)
+	j := 0
+	for i := range points {
+		var d float64
+		if n > 1 {
+			d = total * float64(i) / float64(n-1)
+		}
+		for j < len(line)-2 && dist[j+1] < d {
+			j++
+		}
+		if j == len(line)-1 || dist[j+1] == dist[j] {
+			points[i] = line[j]
+			continue
+		}
+		t := math.Min((d-dist[j])/(dist[j+1]-dist[j]), 1)
+		points[i] = line[j].add(line[j+1].sub(line[j]).scale(t))
+	}
+	points[n-1] = line[len(line)-1]
+	return points
+}
+
+// Resample returns n points along the stroke p, equally spaced. See
+// SVGPath.Resample. The error is from parsing its 'd' attribute.
+func (p *Path) Resample(n int) (points []Point, err error) {
+	path, err := p.SVGPath()
+	if err != nil {
+		return nil, err
+	}
+	return path.Resample(n), nil
+}
@@ -0,0 +1,69 @@
+package kvg
+
+import (
+	"bytes"
+	"io"
+)
+
+// Options for Encode. The zero value, or a nil pointer, writes the
+// same thing as MakeXML.
+type EncodeOptions struct {
+	// Do not renumber the ids of the groups and paths.
+	NoRenumber bool
+	// Do not renumber the stroke number labels.
+	NoRenumberLabels bool
+	// Do not write the heading, or the prolog of a file which was
+	// read, so the output starts with the svg element.
+	NoHeading bool
+}
+
+// Read a KanjiVG file from r and parse it. Like ParseKanji, everything
+// in the file is kept.
+func Decode(r io.Reader) (kanjivg SVG, err error) {
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return kanjivg, err
+	}
+	return ParseKanji(contents)
+}
+
+// Write kanjivg to w as XML, as MakeXML does, with the renumbering and
+// the heading controlled by opts. Nothing is written to w if kanjivg
+// cannot be made into XML. The error value wraps ErrNoBaseGroup or
+// ErrMarshal, as for MakeXML, or comes from w.
+func Encode(w io.Writer, kanjivg *SVG, opts *EncodeOptions) (err error) {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+	if !kanjivg.HasBaseGroup() {
+		return ErrNoBaseGroup
+	}
+	if !opts.NoRenumber {
+		kanjivg.renumberIDs()
+	}
+	if !opts.NoRenumberLabels {
+		kanjivg.RenumberLabels()
+	}
+	xw := writer{noHeading: opts.NoHeading}
+	xw.svg(kanjivg)
+	if xw.err != nil {
+		return xw.err
+	}
+	_, err = xw.buf.WriteTo(w)
+	return err
+}
+
+// Write kanjivg to w. See Encode.
+func (kanjivg *SVG) Encode(w io.Writer, opts *EncodeOptions) (err error) {
+	return Encode(w, kanjivg, opts)
+}
+
+// Make kanjivg into XML with the options opts. See Encode.
+func MakeXMLOptions(kanjivg *SVG, opts *EncodeOptions) (output []byte, err error) {
+	var buf bytes.Buffer
+	err = Encode(&buf, kanjivg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
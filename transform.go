@@ -0,0 +1,282 @@
+package kvg
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Matrix is an affine transform, written in SVG as matrix(A B C D E F),
+// which moves the point (x, y) to (A x + C y + E, B x + D y + F).
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+// Identity is the transform which does not move anything.
+var Identity = Matrix{A: 1, D: 1}
+
+// Translate returns the transform which moves everything by dx and dy.
+func Translate(dx, dy float64) Matrix {
+	return Matrix{A: 1, D: 1, E: dx, F: dy}
+}
+
+// Scale returns the transform which scales by sx and sy, keeping the
+// point about where it is.
+func Scale(sx, sy float64, about Point) Matrix {
+	return Matrix{A: sx, D: sy, E: about.X * (1 - sx), F: about.Y * (1 - sy)}
+}
+
+// Skew returns the transform which skews by the angles ax along the
+// x axis and ay along the y axis, in degrees, keeping the point about
+// where it is.
+func Skew(ax, ay float64, about Point) Matrix {
+	tx, ty := math.Tan(ax*math.Pi/180), math.Tan(ay*math.Pi/180)
+	return Translate(-about.X, -about.Y).
+		Then(Matrix{A: 1, B: ty, C: tx, D: 1}).
+		Then(Translate(about.X, about.Y))
+}
+
+// FitToBox returns the transform which scales the rectangle from
+// equally in both directions, so that it is as big as possible while
+// still fitting into the rectangle to, and puts it in the middle of to.
+func FitToBox(from, to Rect) Matrix {
+	s := 1.0
+	switch {
+	case from.Width() > 0 && from.Height() > 0:
+		s = math.Min(to.Width()/from.Width(), to.Height()/from.Height())
+	case from.Width() > 0:
+		s = to.Width() / from.Width()
+	case from.Height() > 0:
+		s = to.Height() / from.Height()
+	}
+	fc := from.Min.add(from.Max).scale(0.5)
+	tc := to.Min.add(to.Max).scale(0.5)
+	return Matrix{A: s, D: s, E: tc.X - s*fc.X, F: tc.Y - s*fc.Y}
+}
+
+// Then returns the transform which does m and then n.
+func (m Matrix) Then(n Matrix) Matrix {
+	return Matrix{
+		A: n.A*m.A + n.C*m.B,
+		B: n.B*m.A + n.D*m.B,
+		C: n.A*m.C + n.C*m.D,
+		D: n.B*m.C + n.D*m.D,
+		E: n.A*m.E + n.C*m.F + n.E,
+		F: n.B*m.E + n.D*m.F + n.F,
+	}
+}
+
+// Apply returns the point p moved by m.
+func (m Matrix) Apply(p Point) Point {
+	return Point{m.A*p.X + m.C*p.Y + m.E, m.B*p.X + m.D*p.Y + m.F}
+}
+
+// vector returns the direction v changed by m, without moving it.
+func (m Matrix) vector(v Point) Point {
+	return Point{m.A*v.X + m.C*v.Y, m.B*v.X + m.D*v.Y}
+}
+
+// Transform returns the path moved by m. Relative commands stay
+// relative and absolute commands stay absolute. H and V become L,
+// since they may no longer be horizontal or vertical, and arcs become
+// absolute cubic Béziers.
+func (p SVGPath) Transform(m Matrix) (t SVGPath) {
+	abs := p.convert(true)
+	var cur Point
+	for i, subpath := range p.Subpaths {
+		var commands []Command
+		for j, command := range subpath.Commands {
+			a := abs.Subpaths[i].Commands[j].Params
+			relative := !command.IsAbsolute()
+			move := m.Apply
+			if relative {
+				move = m.vector
+			}
+			params := command.Params
+			symbol := command.Symbol
+			switch strings.ToLower(symbol) {
+			case "h":
+				params = []float64{params[0], 0}
+				symbol = "l"
+				if !relative {
+					params[1] = cur.Y
+					symbol = "L"
+				}
+			case "v":
+				params = []float64{0, params[0]}
+				symbol = "l"
+				if !relative {
+					params[0] = cur.X
+					symbol = "L"
+				}
+			case "a":
+				for _, c := range arcToCubics(cur.X, cur.Y, a) {
+					commands = append(commands, Command{c.Symbol, transformPairs(c.Params, m.Apply)})
+				}
+				cur = Point{a[5], a[6]}
+				continue
+			}
+			moved := transformPairs(params, move)
+			if relative && i == 0 && j == 0 && len(params) >= 2 {
+				// The first moveto of a path is absolute even if it
+				// is written "m", so it is moved, not just turned.
+				q := m.Apply(Point{params[0], params[1]})
+				moved[0], moved[1] = q.X, q.Y
+			}
+			commands = append(commands, Command{symbol, moved})
+			switch strings.ToLower(command.Symbol) {
+			case "z":
+				// The start of the subpath is the last moveto.
+				for k := j; k >= 0; k-- {
+					if strings.ToLower(subpath.Commands[k].Symbol) == "m" {
+						s := abs.Subpaths[i].Commands[k].Params
+						cur = Point{s[0], s[1]}
+						break
+					}
+				}
+			case "h":
+				cur.X = a[0]
+			case "v":
+				cur.Y = a[0]
+			default:
+				cur = Point{a[len(a)-2], a[len(a)-1]}
+			}
+		}
+		t.Subpaths = append(t.Subpaths, Subpath{commands})
+	}
+	return t
+}
+
+// transformPairs returns the x, y pairs in params moved by move.
+func transformPairs(params []float64, move func(Point) Point) []float64 {
+	out := make([]float64, len(params))
+	for i := 0; i+1 < len(params); i += 2 {
+		q := move(Point{params[i], params[i+1]})
+		out[i], out[i+1] = q.X, q.Y
+	}
+	return out
+}
+
+// The precision of the numbers of paths and labels written by
+// Transform, as in the KanjiVG files.
+const transformPrecision = 2
+
+// Transform moves the stroke p by m, rewriting its 'd' attribute in
+// the KanjiVG style with two decimal places. The error is from parsing
+// the 'd' attribute, in which case p is not changed.
+func (p *Path) Transform(m Matrix) (err error) {
+	path, err := p.SVGPath()
+	if err != nil {
+		return err
+	}
+	p.D = path.Transform(m).Text(&PathOptions{Precision: transformPrecision})
+	return nil
+}
+
+// Transform moves all the strokes of g by m. If labels, the
+// StrokeNumbers group such as svg.Groups[1], is not nil, the label of
+// each stroke is also moved, though the text itself is not scaled. The
+// label of a stroke is the text with the same number as the id of the
+// stroke, as RenumberXML leaves them. If the 'd' attribute of any
+// stroke cannot be parsed, nothing is changed and the error is a
+// PathError.
+func (g *Group) Transform(m Matrix, labels *Group) (err error) {
+	paths := g.GetPaths()
+	ds := make([]string, len(paths))
+	for i, p := range paths {
+		path, err := p.SVGPath()
+		if err != nil {
+			return &PathError{ID: p.ID, Err: err}
+		}
+		ds[i] = path.Transform(m).Text(&PathOptions{Precision: transformPrecision})
+	}
+	for i, p := range paths {
+		p.D = ds[i]
+		if labels == nil {
+			continue
+		}
+		t := labels.Label(p)
+		if t == nil {
+			continue
+		}
+		pos, err := t.Position()
+		if err != nil {
+			continue
+		}
+		t.SetPosition(m.Apply(pos))
+	}
+	return nil
+}
+
+// Label returns the text in labels, the StrokeNumbers group, for the
+// stroke p, going by the number in the id of p, or nil if there is no
+// such text.
+func (labels *Group) Label(p *Path) *Text {
+	num, err := PathIDToNum(p.ID)
+	if err != nil {
+		return nil
+	}
+	n := int64(0)
+	for i := range labels.Children {
+		c := &labels.Children[i]
+		if !c.IsText {
+			continue
+		}
+		n++
+		if n == num {
+			return &c.Text
+		}
+	}
+	return nil
+}
+
+// Position returns where the text t is, from its transform attribute,
+// which in the KanjiVG files is of the form "matrix(1 0 0 1 x y)".
+func (t *Text) Position() (p Point, err error) {
+	m, err := parseMatrix(t.Transform)
+	if err != nil {
+		return p, err
+	}
+	return Point{m.E, m.F}, nil
+}
+
+// SetPosition moves the text t to p, keeping the rest of its
+// transform. If it has no transform, or one which cannot be parsed,
+// it is given the KanjiVG form "matrix(1 0 0 1 x y)".
+func (t *Text) SetPosition(p Point) {
+	m, err := parseMatrix(t.Transform)
+	if err != nil {
+		m = Identity
+	}
+	m.E, m.F = p.X, p.Y
+	nums := []float64{m.A, m.B, m.C, m.D, m.E, m.F}
+	strs := make([]string, len(nums))
+	for i, n := range nums {
+		strs[i] = formatNumber(n, transformPrecision)
+	}
+	t.Transform = "matrix(" + strings.Join(strs, " ") + ")"
+}
+
+// parseMatrix reads a transform attribute of the form matrix(a b c d
+// e f), with spaces or commas between the numbers.
+func parseMatrix(s string) (m Matrix, err error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "matrix(") || !strings.HasSuffix(s, ")") {
+		return m, fmt.Errorf("transform '%s' is not a matrix", s)
+	}
+	fields := strings.FieldsFunc(s[len("matrix("):len(s)-1], func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(fields) != 6 {
+		return m, fmt.Errorf("transform '%s' does not have six numbers", s)
+	}
+	var v [6]float64
+	for i, f := range fields {
+		v[i], err = strconv.ParseFloat(f, 64)
+		if err != nil {
+			return m, fmt.Errorf("transform '%s': %w", s, err)
+		}
+	}
+	return Matrix{v[0], v[1], v[2], v[3], v[4], v[5]}, nil
+}
@@ -0,0 +1,55 @@
+package kvg
+
+import "testing"
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		d      string
+		m      Matrix
+		expect string
+	}{
+		{"M1,2c1,1,2,2,3,3", Translate(10, 20), "M11,22c1,1,2,2,3,3"},
+		{"M1,2C1,1,2,2,3,3", Translate(10, 20), "M11,22C11,21,12,22,13,23"},
+		{"M1,2h3v4H0V0", Scale(2, 3, Point{1, 2}), "M1,2l6,0l0,12L-1,14L-1-4"},
+		{"M0,0l1,0", Skew(45, 0, Point{}), "M0,0l1,0"},
+		{"M0,0l0,1", Skew(45, 0, Point{}), "M0,0l1,1"},
+		// The first moveto is absolute even when it is written "m".
+		{"m1,2l3,4", Translate(10, 20), "m11,22l3,4"},
+		{"m1,2l3,4m1,1l1,0", Scale(2, 2, Point{}), "m2,4l6,8m2,2l2,0"},
+	}
+	for _, test := range tests {
+		path, err := PathParser(test.d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := path.Transform(test.m).Text(&PathOptions{Precision: 2})
+		if got != test.expect {
+			t.Errorf("%s: expected %s, got %s", test.d, test.expect, got)
+		}
+	}
+	m := FitToBox(Rect{Point{10, 10}, Point{30, 20}}, Rect{Point{0, 0}, Point{100, 100}})
+	if m.Apply(Point{10, 10}) != (Point{0, 25}) || m.Apply(Point{30, 20}) != (Point{100, 75}) {
+		t.Errorf("Bad fit %+v", m)
+	}
+	svg, err := ReadKanjiFile(bin() + "/t/08475.svg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Move the grass radical down by ten.
+	labels := &svg.Groups[1]
+	grass := &svg.BaseGroup().Children[0].Group
+	err = grass.Transform(Translate(0, 10), labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := svg.GetPaths()
+	if paths[0].D != "M20.5,33.7c2.92,0.68,5.69,0.64,8.64,0.29c14.99-1.75,36.05-2.91,49.75-3.33c3.29-0.1,6.36-0.02,9.62,0.44" {
+		t.Errorf("Bad first stroke %s", paths[0].D)
+	}
+	if labels.Children[0].Text.Transform != "matrix(1 0 0 1 14.25 33.25)" {
+		t.Errorf("Bad first label %s", labels.Children[0].Text.Transform)
+	}
+	if labels.Label(paths[3]).Transform != "matrix(1 0 0 1 27.25 35.25)" {
+		t.Errorf("Label of a stroke outside the group was moved")
+	}
+}
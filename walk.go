@@ -0,0 +1,181 @@
+package kvg
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A file read in and parsed by WalkDir or Corpus.Walk.
+type KanjiFile struct {
+	// The name of the file within its corpus, such as "08475.svg".
+	Name string
+	// The full name of the file, as given by Corpus.Path.
+	Path string
+	// The bytes of the file as read.
+	Contents []byte
+	// The parsed file.
+	SVG SVG
+}
+
+// Function called by Walk for each file. If it returns an error,
+// the error is collected along with the file name, and the walk
+// carries on with the other files.
+type WalkFunc func(kf *KanjiFile) error
+
+// Options for Walk.
+type WalkOptions struct {
+	// The number of files to read and parse at the same time. If this
+	// is zero or less, the number of CPUs is used.
+	Workers int
+	// If this is true, the function is called for one file at a time
+	// in the order of the file names, so it can write to variables or
+	// print without locking. The reading and parsing still happen in
+	// parallel. If this is false, the function is called from
+	// several goroutines at once, in no particular order.
+	Ordered bool
+	// If this is not nil, files which have not changed since they
+	// were stored in the cache are not parsed again. New and changed
+	// files are added to the cache, but it is up to the caller to
+	// call Cache.Save afterwards.
+	Cache *Cache
+}
+
+// An error which happened while reading, parsing or examining a file.
+type FileError struct {
+	File string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("%s: %s", e.File, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// The errors from all of the files of a walk, in order of file name.
+type FileErrors []*FileError
+
+func (errs FileErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Read, parse and call fn on all of the KanjiVG files in dir. This is
+// the same as Corpus.Walk for the corpus of dir.
+func WalkDir(ctx context.Context, dir string, opts *WalkOptions, fn WalkFunc) (err error) {
+	return NewCorpus(dir).Walk(ctx, opts, fn)
+}
+
+// The result of reading one file of a walk.
+type walkResult struct {
+	index int
+	kf    *KanjiFile
+	err   error
+}
+
+// Read, parse and call fn on all of the KanjiVG files in the corpus,
+// using several goroutines, as controlled by opts, which may be nil
+// for the defaults. Errors from reading or parsing a file, or from fn,
+// do not stop the walk. They are returned together as FileErrors once
+// all the files have been dealt with. If ctx is cancelled, the walk
+// stops as soon as possible and returns the error from the
+// context. See ExamineAllFiles for a simpler function which works on
+// one file at a time.
+func (c *Corpus) Walk(ctx context.Context, opts *WalkOptions, fn WalkFunc) (err error) {
+	files, err := c.Files()
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		opts = &WalkOptions{}
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan int)
+	results := make(chan walkResult)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				kf, err := c.readKanjiFile(files[i], opts.Cache)
+				if err == nil && !opts.Ordered {
+					err = fn(kf)
+				}
+				select {
+				case results <- walkResult{i, kf, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var errs FileErrors
+	addError := func(r walkResult) {
+		errs = append(errs, &FileError{File: c.Path(files[r.index]), Err: r.err})
+	}
+	// Results which have arrived before their turn, for Ordered.
+	pending := make(map[int]walkResult)
+	next := 0
+	for r := range results {
+		if !opts.Ordered {
+			if r.err != nil {
+				addError(r)
+			}
+			continue
+		}
+		pending[r.index] = r
+		for {
+			p, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if p.err == nil && ctx.Err() == nil {
+				p.err = fn(p.kf)
+			}
+			if p.err != nil {
+				addError(p)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].File < errs[j].File
+	})
+	return errs
+}
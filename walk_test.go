@@ -0,0 +1,82 @@
+package kvg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Make a directory of copies of the test file, plus a broken file and
+// a backup file.
+func makeTestDir(t *testing.T) (dir string, names []string) {
+	dir = t.TempDir()
+	contents := read(bin() + "/t/08475.svg")
+	names = []string{"04e00.svg", "08475-Kaisho.svg", "08475.svg", "09f8d.svg"}
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(filepath.Join(dir, "05000.svg"), []byte("<svg"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "08475.svg~"), []byte("<svg"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, names
+}
+
+func TestWalkDir(t *testing.T) {
+	dir, names := makeTestDir(t)
+	var got []string
+	opts := WalkOptions{Workers: 3, Ordered: true}
+	err := WalkDir(context.Background(), dir, &opts, func(kf *KanjiFile) error {
+		got = append(got, filepath.Base(kf.Path))
+		if kf.SVG.BaseGroup().Element != "葵" {
+			t.Errorf("%s: bad parse", kf.Path)
+		}
+		return nil
+	})
+	var errs FileErrors
+	if !errors.As(err, &errs) || len(errs) != 1 ||
+		filepath.Base(errs[0].File) != "05000.svg" {
+		t.Errorf("Expected one error for 05000.svg, got %v", err)
+	}
+	if len(got) != len(names) {
+		t.Fatalf("Expected %d files, got %d", len(names), len(got))
+	}
+	for i := range names {
+		if got[i] != names[i] {
+			t.Errorf("File %d: expected %s, got %s", i, names[i], got[i])
+		}
+	}
+	// Unordered, with errors from the function.
+	var mu sync.Mutex
+	n := 0
+	err = WalkDir(context.Background(), dir, nil, func(kf *KanjiFile) error {
+		mu.Lock()
+		n++
+		mu.Unlock()
+		return errors.New("no good")
+	})
+	if !errors.As(err, &errs) || len(errs) != len(names)+1 {
+		t.Errorf("Expected %d errors, got %v", len(names)+1, err)
+	}
+	if n != len(names) {
+		t.Errorf("Expected %d calls, got %d", len(names), n)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = WalkDir(ctx, dir, nil, func(kf *KanjiFile) error {
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancellation error, got %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package kvg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// The namespace of xml:lang and so on, which is always bound to the
+// prefix "xml".
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Writes an SVG as XML, using the recorded formatting of each element
+// where possible, and the KanjiVG style otherwise.
+type writer struct {
+	buf bytes.Buffer
+	// Maps from namespace URLs to prefixes, innermost last, from the
+	// xmlns attributes of the elements being written.
+	prefixes []map[string]string
+	// The first error found, such as an attribute in a namespace with
+	// no prefix.
+	err error
+	// Leave out the heading or prolog.
+	noHeading bool
+}
+
+// The KanjiVG style indentation of a tag at depth, where the svg
+// element is at depth zero. The groups and paths are indented by one
+// tab for each level inside the base group, and the text elements
+// are indented by one tab.
+func indent(depth int, isText bool) string {
+	tabs := depth - 2
+	if isText {
+		tabs = depth - 1
+	}
+	if tabs < 0 {
+		tabs = 0
+	}
+	return "\n" + strings.Repeat("\t", tabs)
+}
+
+// Add v to the output, escaped as XML.
+func (w *writer) escape(v string) {
+	xml.EscapeText(&w.buf, []byte(v))
+}
+
+// Make the name of an attribute into text, using the prefix declared
+// for its namespace. KVGNamespace has the prefix "kvg" unless the file
+// declares some other prefix for it. Names with unbound prefixes,
+// where the space is the prefix itself, are left as they are.
+func (w *writer) attrName(n xml.Name) string {
+	switch n.Space {
+	case "":
+		return n.Local
+	case xmlNamespace:
+		return "xml:" + n.Local
+	}
+	for i := len(w.prefixes) - 1; i >= 0; i-- {
+		if prefix, ok := w.prefixes[i][n.Space]; ok {
+			return prefix + ":" + n.Local
+		}
+	}
+	if n.Space == KVGNamespace {
+		return "kvg:" + n.Local
+	}
+	if strings.Contains(n.Space, ":") && w.err == nil {
+		// This is a namespace URL rather than a prefix.
+		w.err = fmt.Errorf("%w: no prefix for namespace %s of attribute %s",
+			ErrMarshal, n.Space, n.Local)
+	}
+	return n.Space + ":" + n.Local
+}
+
+// Note the namespace prefixes declared by attrs.
+func (w *writer) pushPrefixes(attrs []xml.Attr) {
+	var m map[string]string
+	for _, a := range attrs {
+		if a.Name.Space == "xmlns" {
+			if m == nil {
+				m = make(map[string]string)
+			}
+			m[a.Value] = a.Name.Local
+		}
+	}
+	w.prefixes = append(w.prefixes, m)
+}
+
+func (w *writer) popPrefixes() {
+	w.prefixes = w.prefixes[:len(w.prefixes)-1]
+}
+
+// Are the two lists of attributes the same?
+func sameAttrs(a, b []xml.Attr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Put attrs into the order of the attributes in f, with any which are
+// not in f at the end, in their original order.
+func orderAttrs(attrs []xml.Attr, f *Format) []xml.Attr {
+	if len(f.Attrs) == 0 {
+		return attrs
+	}
+	ordered := make([]xml.Attr, 0, len(attrs))
+	used := make([]bool, len(attrs))
+	for _, fa := range f.Attrs {
+		for i, a := range attrs {
+			if !used[i] && a.Name == fa.Name {
+				ordered = append(ordered, a)
+				used[i] = true
+				break
+			}
+		}
+	}
+	for i, a := range attrs {
+		if !used[i] {
+			ordered = append(ordered, a)
+		}
+	}
+	return ordered
+}
+
+// Write the start tag of the element called name with attrs. If empty
+// is true, the tag is closed like <path/>.
+func (w *writer) startTag(name string, attrs []xml.Attr, f *Format, empty bool) {
+	wasEmpty := len(f.Tag) > 0 && len(f.End) == 0
+	if len(f.Tag) > 0 && empty == wasEmpty && sameAttrs(attrs, f.Attrs) {
+		w.buf.WriteString(f.Tag)
+		return
+	}
+	w.buf.WriteString("<" + name)
+	for _, a := range orderAttrs(attrs, f) {
+		w.buf.WriteString(" " + w.attrName(a.Name) + `="`)
+		w.escape(a.Value)
+		w.buf.WriteString(`"`)
+	}
+	if empty {
+		w.buf.WriteString("/>")
+		return
+	}
+	w.buf.WriteString(">")
+}
+
+// Write the end tag of the element called name.
+func (w *writer) endTag(name string, f *Format) {
+	if len(f.End) > 0 {
+		w.buf.WriteString(f.End)
+		return
+	}
+	w.buf.WriteString("</" + name + ">")
+}
+
+// Does f have a recorded space for each of n children?
+func hasSpace(f *Format, n int) bool {
+	return len(f.Tag) > 0 && len(f.Space) == n+1
+}
+
+// Add an attribute to attrs if value is not empty, or if always is
+// true.
+func addAttr(attrs []xml.Attr, space, local, value string, always bool) []xml.Attr {
+	if len(value) == 0 && !always {
+		return attrs
+	}
+	return append(attrs, xml.Attr{Name: xml.Name{Space: space, Local: local}, Value: value})
+}
+
+// Add a true/false attribute to attrs if value is true.
+func addBoolAttr(attrs []xml.Attr, space, local string, value bool) []xml.Attr {
+	if !value {
+		return attrs
+	}
+	return addAttr(attrs, space, local, "true", true)
+}
+
+// The attributes of g, in the KanjiVG order.
+func (g *Group) attrs() (attrs []xml.Attr) {
+	attrs = addAttr(attrs, "", "id", g.ID, false)
+	attrs = addAttr(attrs, KVGNamespace, "element", g.Element, false)
+	attrs = addAttr(attrs, KVGNamespace, "part", g.Part, false)
+	attrs = addBoolAttr(attrs, KVGNamespace, "variant", g.Variant)
+	attrs = addAttr(attrs, KVGNamespace, "number", g.Number, false)
+	attrs = addAttr(attrs, KVGNamespace, "original", g.Original, false)
+	attrs = addBoolAttr(attrs, KVGNamespace, "partial", g.Partial)
+	attrs = addAttr(attrs, KVGNamespace, "tradForm", g.TradForm, false)
+	attrs = addAttr(attrs, KVGNamespace, "position", g.Position, false)
+	attrs = addAttr(attrs, KVGNamespace, "radical", g.Radical, false)
+	attrs = addAttr(attrs, KVGNamespace, "phon", g.Phon, false)
+	attrs = addAttr(attrs, KVGNamespace, "radicalForm", g.RadicalForm, false)
+	attrs = addAttr(attrs, "", "style", g.Style, false)
+	return append(attrs, g.Extra...)
+}
+
+// The attributes of p, in the KanjiVG order.
+func (p *Path) attrs() (attrs []xml.Attr) {
+	attrs = addAttr(attrs, "", "id", p.ID, true)
+	attrs = addAttr(attrs, KVGNamespace, "type", p.Type, false)
+	attrs = addAttr(attrs, "", "d", p.D, true)
+	attrs = addAttr(attrs, "", "class", p.Class, false)
+	return append(attrs, p.Extra...)
+}
+
+// The attributes of t, in the KanjiVG order.
+func (t *Text) attrs() (attrs []xml.Attr) {
+	attrs = addAttr(attrs, "", "transform", t.Transform, false)
+	attrs = addAttr(attrs, "", "class", t.Class, false)
+	return append(attrs, t.Extra...)
+}
+
+// The attributes of svg, in the KanjiVG order.
+func (svg *SVG) attrs() (attrs []xml.Attr) {
+	attrs = addAttr(attrs, "", "xmlns", svg.XMLNS, true)
+	attrs = addAttr(attrs, "", "width", svg.Width, true)
+	attrs = addAttr(attrs, "", "height", svg.Height, true)
+	attrs = addAttr(attrs, "", "viewBox", svg.ViewBox, false)
+	return append(attrs, svg.Extra...)
+}
+
+// Write a whole file.
+func (w *writer) svg(svg *SVG) {
+	f := &svg.Format
+	switch {
+	case w.noHeading:
+	case len(f.Tag) > 0:
+		w.buf.WriteString(svg.Prolog)
+	default:
+		w.buf.WriteString(Heading)
+	}
+	attrs := svg.attrs()
+	w.pushPrefixes(attrs)
+	defer w.popPrefixes()
+	w.startTag("svg", attrs, f, false)
+	recorded := hasSpace(f, len(svg.Groups))
+	for i := range svg.Groups {
+		if recorded {
+			w.buf.WriteString(f.Space[i])
+		} else {
+			w.buf.WriteString(indent(1, false))
+		}
+		w.group(&svg.Groups[i], 1)
+	}
+	if recorded {
+		w.buf.WriteString(f.Space[len(svg.Groups)])
+	} else {
+		w.buf.WriteString(indent(0, false))
+	}
+	w.endTag("svg", f)
+	if len(f.Tag) > 0 {
+		w.buf.WriteString(svg.Epilog)
+	} else {
+		w.buf.WriteString("\n")
+	}
+}
+
+// Write the group g at depth.
+func (w *writer) group(g *Group, depth int) {
+	f := &g.Format
+	attrs := g.attrs()
+	w.pushPrefixes(attrs)
+	defer w.popPrefixes()
+	n := len(g.Children)
+	w.startTag("g", attrs, f, n == 0 && len(f.Tag) > 0 && len(f.End) == 0)
+	if n == 0 && len(f.Tag) > 0 && len(f.End) == 0 {
+		return
+	}
+	recorded := hasSpace(f, n)
+	for i := range g.Children {
+		c := &g.Children[i]
+		if recorded {
+			w.buf.WriteString(f.Space[i])
+		} else {
+			w.buf.WriteString(indent(depth+1, c.IsText))
+		}
+		w.child(c, depth+1)
+	}
+	if recorded {
+		w.buf.WriteString(f.Space[n])
+	} else if n > 0 {
+		w.buf.WriteString(indent(depth, false))
+	}
+	w.endTag("g", f)
+}
+
+// Write the child c at depth.
+func (w *writer) child(c *Child, depth int) {
+	switch {
+	case c.IsGroup:
+		w.group(&c.Group, depth)
+	case c.IsText:
+		w.text(&c.Text)
+	case c.IsOther:
+		w.buf.WriteString(c.Other.Raw)
+	default:
+		w.path(&c.Path)
+	}
+}
+
+// Write the path p.
+func (w *writer) path(p *Path) {
+	f := &p.Format
+	attrs := p.attrs()
+	w.pushPrefixes(attrs)
+	defer w.popPrefixes()
+	empty := len(f.Tag) == 0 || len(f.End) == 0
+	w.startTag("path", attrs, f, empty)
+	if empty {
+		return
+	}
+	if len(f.Space) > 0 {
+		w.buf.WriteString(f.Space[0])
+	}
+	w.endTag("path", f)
+}
+
+// The character data within the text of raw, which is the contents of
+// a text element.
+func rawContent(raw string) []byte {
+	d := xml.NewDecoder(strings.NewReader("<text>" + raw + "</text>"))
+	var content bytes.Buffer
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			content.Write(cd)
+		}
+	}
+	return content.Bytes()
+}
+
+// Write the text t.
+func (w *writer) text(t *Text) {
+	f := &t.Format
+	attrs := t.attrs()
+	w.pushPrefixes(attrs)
+	defer w.popPrefixes()
+	empty := len(f.Tag) > 0 && len(f.End) == 0 && len(t.Content) == 0
+	w.startTag("text", attrs, f, empty)
+	if empty {
+		return
+	}
+	// Use the contents as they were in the file, unless the content
+	// has been altered.
+	if len(f.Space) == 1 && bytes.Equal(rawContent(f.Space[0]), t.Content) {
+		w.buf.WriteString(f.Space[0])
+	} else {
+		w.escape(string(t.Content))
+	}
+	w.endTag("text", f)
+}
+
+// Forget the recorded formatting of svg, so that it is written in the
+// style of the KanjiVG files, with the standard Heading. Comments and
+// elements which this library does not know about are kept, but
+// anything else in the svg element other than groups is lost.
+func (svg *SVG) ClearFormat() {
+	svg.Format = Format{}
+	svg.Prolog = ""
+	svg.Epilog = ""
+	for i := range svg.Groups {
+		svg.Groups[i].ClearFormat()
+	}
+}
+
+// Forget the recorded formatting of g and everything in it.
+func (g *Group) ClearFormat() {
+	g.Format = Format{}
+	for i := range g.Children {
+		c := &g.Children[i]
+		c.Group.ClearFormat()
+		c.Path.Format = Format{}
+		c.Text.Format = Format{}
+	}
+}
+
+// Encode the XML in raw into e, for marshalling the unknown parts of
+// a file.
+func encodeRaw(e *xml.Encoder, raw string) error {
+	d := xml.NewDecoder(strings.NewReader(raw))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			break
+		}
+		err = e.EncodeToken(xml.CopyToken(tok))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}